@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// dnsEditPermission is the zone permission needed to sync a zone. It's shown
+// as "Zone / DNS / Edit" when creating an API token.
+const dnsEditPermission = "#dns_records:edit"
+
+// tokenAPI is the subset of the Cloudflare API needed for verifying an API
+// token.
+type tokenAPI interface {
+	Raw(method string, endpoint string, data interface{}) (json.RawMessage, error)
+	ZoneDetails(zoneID string) (cloudflare.Zone, error)
+}
+
+// newAPI will return a Cloudflare API client using the credentials from the
+// environment. An API token is preferred over the global API key.
+func newAPI() (*cloudflare.API, error) {
+	var api *cloudflare.API
+	var err error
+
+	opts := []cloudflare.Option{}
+	if accountID != "" {
+		opts = append(opts, cloudflare.UsingAccount(accountID))
+	}
+
+	switch {
+	case apiToken != "":
+		api, err = cloudflare.NewWithAPIToken(apiToken, opts...)
+
+	case apiKey != "" && apiEmail != "":
+		api, err = cloudflare.New(apiKey, apiEmail, opts...)
+
+	default:
+		return nil, errors.New("Please set CF_API_TOKEN, or CF_API_KEY and CF_API_EMAIL environment variables")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Error contacting Cloudflare: %s", err.Error())
+	}
+
+	return api, nil
+}
+
+// verifyToken will return an error if the API token used by api is not
+// active.
+func verifyToken(api tokenAPI) error {
+	raw, err := api.Raw("GET", "/user/tokens/verify", nil)
+	if err != nil {
+		return fmt.Errorf("Can't verify API token: %s", err.Error())
+	}
+
+	var result struct {
+		Status string `json:"status"`
+	}
+
+	err = json.Unmarshal(raw, &result)
+	if err != nil {
+		return fmt.Errorf("Can't verify API token: %s", err.Error())
+	}
+
+	if result.Status != "active" {
+		return fmt.Errorf("API token is not active (status: %s)", result.Status)
+	}
+
+	return nil
+}
+
+// checkPermission will return an error naming the missing permission if the
+// credentials used by api can't edit DNS records in the zone.
+func checkPermission(api tokenAPI, zoneID string, zoneName string) error {
+	zone, err := api.ZoneDetails(zoneID)
+	if err != nil {
+		return fmt.Errorf("Can't get zone details for '%s': %s", zoneName, err.Error())
+	}
+
+	for _, permission := range zone.Permissions {
+		if permission == dnsEditPermission {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("API token lacks the 'Zone / DNS / Edit' permission (%s) for '%s'", dnsEditPermission, zoneName)
+}
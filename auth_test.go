@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// fakeTokenAPI is a fake implementation of tokenAPI.
+type fakeTokenAPI struct {
+	verify      string
+	verifyErr   error
+	permissions []string
+}
+
+func (f *fakeTokenAPI) Raw(method string, endpoint string, data interface{}) (json.RawMessage, error) {
+	return json.RawMessage(f.verify), f.verifyErr
+}
+
+func (f *fakeTokenAPI) ZoneDetails(zoneID string) (cloudflare.Zone, error) {
+	return cloudflare.Zone{ID: zoneID, Permissions: f.permissions}, nil
+}
+
+func TestVerifyToken(t *testing.T) {
+	cases := []struct {
+		api *fakeTokenAPI
+		err bool
+	}{
+		{&fakeTokenAPI{verify: `{"id":"abc","status":"active"}`}, false},
+		{&fakeTokenAPI{verify: `{"id":"abc","status":"disabled"}`}, true},
+		{&fakeTokenAPI{verify: `garbage`}, true},
+		{&fakeTokenAPI{verifyErr: errors.New("Invalid API Token")}, true},
+	}
+
+	for i, in := range cases {
+		err := verifyToken(in.api)
+		if in.err && err == nil {
+			t.Errorf("%d: verifyToken() failed to err", i)
+		}
+
+		if !in.err && err != nil {
+			t.Errorf("%d: verifyToken() returned error: %s", i, err.Error())
+		}
+	}
+}
+
+func TestCheckPermission(t *testing.T) {
+	err := checkPermission(&fakeTokenAPI{permissions: []string{"#zone:read", "#dns_records:read", "#dns_records:edit"}}, "id", "example.com")
+	if err != nil {
+		t.Errorf("checkPermission() returned error: %s", err.Error())
+	}
+
+	err = checkPermission(&fakeTokenAPI{permissions: []string{"#zone:read", "#dns_records:read"}}, "id", "example.com")
+	if err == nil {
+		t.Fatalf("checkPermission() failed to err on missing permission")
+	}
+
+	if !strings.Contains(err.Error(), dnsEditPermission) {
+		t.Errorf("checkPermission() did not name the missing permission: %s", err.Error())
+	}
+}
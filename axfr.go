@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// axfrScheme is the URL scheme for zones read by zone transfer.
+	axfrScheme = "axfr"
+
+	// tsigFudge is the allowed clock skew in seconds for TSIG signatures.
+	tsigFudge = 300
+)
+
+// tsigKey is a TSIG key used for signing messages. An empty name disables
+// signing.
+type tsigKey struct {
+	Name      string
+	Algorithm string
+	Secret    string
+}
+
+// newTSIGKey will return a TSIG key with the name and algorithm in canonical
+// form.
+func newTSIGKey(name string, algorithm string, secret string) (tsigKey, error) {
+	if name == "" {
+		return tsigKey{}, nil
+	}
+
+	if secret == "" {
+		return tsigKey{}, fmt.Errorf("Please specify the TSIG secret using -tsig-secret or CFZONE_TSIG_SECRET")
+	}
+
+	return tsigKey{
+		Name:      dns.Fqdn(strings.ToLower(name)),
+		Algorithm: dns.Fqdn(strings.ToLower(algorithm)),
+		Secret:    secret,
+	}, nil
+}
+
+// sign will add a TSIG record to m if k is set. The returned map holds the
+// secrets for the client or transfer.
+func (k tsigKey) sign(m *dns.Msg) map[string]string {
+	if k.Name == "" {
+		return nil
+	}
+
+	m.SetTsig(k.Name, k.Algorithm, tsigFudge, time.Now().Unix())
+
+	return k.secrets()
+}
+
+// secrets will return the secrets for a client, transfer or server using k,
+// or nil if k is not set.
+func (k tsigKey) secrets() map[string]string {
+	if k.Name == "" {
+		return nil
+	}
+
+	return map[string]string{k.Name: k.Secret}
+}
+
+// withDefaultPort will add the DNS port to server if no port is given. IPv6
+// addresses can be given with or without brackets.
+func withDefaultPort(server string) string {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		return net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+
+	return server
+}
+
+// transferZone will transfer zone from server using AXFR. The SOA record
+// closing the transfer is left out.
+func transferZone(server string, zone string, key tsigKey) ([]dns.RR, error) {
+	m := new(dns.Msg)
+	m.SetAxfr(zone)
+
+	t := &dns.Transfer{TsigSecret: key.sign(m)}
+
+	envelopes, err := t.In(m, server)
+	if err != nil {
+		return nil, fmt.Errorf("Can't transfer '%s' from %s: %s", zone, server, err.Error())
+	}
+
+	rrs := []dns.RR{}
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			return nil, fmt.Errorf("Can't transfer '%s' from %s: %s", zone, server, envelope.Error.Error())
+		}
+
+		rrs = append(rrs, envelope.RR...)
+	}
+
+	if len(rrs) > 1 {
+		rrs = rrs[:len(rrs)-1]
+	}
+
+	return rrs, nil
+}
+
+// isAXFRURL will return true if path is an axfr:// URL.
+func isAXFRURL(path string) bool {
+	return strings.HasPrefix(path, axfrScheme+"://")
+}
+
+// parseAXFRURL will return the server and zone from an URL on the form
+// axfr://server[:port]/zone.
+func parseAXFRURL(rawurl string) (string, string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", "", err
+	}
+
+	if u.Scheme != axfrScheme || u.Host == "" {
+		return "", "", fmt.Errorf("expected %s://server/zone", axfrScheme)
+	}
+
+	zone := strings.Trim(u.Path, "/")
+	if zone == "" || strings.Contains(zone, "/") {
+		return "", "", fmt.Errorf("expected %s://server/zone", axfrScheme)
+	}
+
+	return withDefaultPort(u.Host), zone, nil
+}
+
+// transferChecksum will return the SHA256 checksum of the canonical content
+// of a transfer. The records are sorted, the checksum doesn't depend on the
+// order the server sends them in.
+func transferChecksum(rrs []dns.RR) []byte {
+	lines := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		lines = append(lines, rr.String())
+	}
+
+	sort.Strings(lines)
+
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n") + "\n"))
+
+	return sum[:]
+}
+
+// parseTransfer will convert the records transferred for the zone called
+// name to a parsedZone like parseZoneFile does.
+func parseTransfer(name string, rrs []dns.RR, autoTTL, cacheTTL int) (*parsedZone, error) {
+	z := &parsedZone{
+		Name:      strings.Trim(name, "."),
+		Records:   recordCollection{},
+		Protected: recordCollection{},
+	}
+
+	for _, rr := range rrs {
+		r, err := newRecord(rr, autoTTL, cacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", rr.String(), err.Error())
+		}
+
+		if r != nil {
+			z.Records = append(z.Records, *r)
+		}
+	}
+
+	return z, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseAXFRURL(t *testing.T) {
+	cases := []struct {
+		in     string
+		server string
+		zone   string
+		err    bool
+	}{
+		{"axfr://ns1.example.com/example.com", "ns1.example.com:53", "example.com", false},
+		{"axfr://127.0.0.1:5353/example.com.", "127.0.0.1:5353", "example.com.", false},
+		{"axfr://[::1]/example.com", "[::1]:53", "example.com", false},
+		{"axfr://ns1.example.com/", "", "", true},
+		{"axfr:///example.com", "", "", true},
+		{"axfr://ns1.example.com/example.com/www", "", "", true},
+		{"http://ns1.example.com/example.com", "", "", true},
+	}
+
+	for i, in := range cases {
+		server, zone, err := parseAXFRURL(in.in)
+		if in.err && err == nil {
+			t.Errorf("%d: parseAXFRURL() failed to err on '%s'", i, in.in)
+		}
+
+		if !in.err && err != nil {
+			t.Errorf("%d: parseAXFRURL() returned error: %s", i, err.Error())
+		}
+
+		if server != in.server || zone != in.zone {
+			t.Errorf("%d: parseAXFRURL() returned '%s', '%s', expected '%s', '%s'", i, server, zone, in.server, in.zone)
+		}
+	}
+}
+
+func TestTransferChecksum(t *testing.T) {
+	a, _ := dns.NewRR("www.example.com. 300 IN A 127.0.0.1")
+	b, _ := dns.NewRR("example.com. 300 IN MX 10 mx.example.com.")
+	c, _ := dns.NewRR("www.example.com. 300 IN A 127.0.0.2")
+
+	if !bytes.Equal(transferChecksum([]dns.RR{a, b}), transferChecksum([]dns.RR{b, a})) {
+		t.Errorf("transferChecksum() depends on the order of records")
+	}
+
+	if bytes.Equal(transferChecksum([]dns.RR{a, b}), transferChecksum([]dns.RR{c, b})) {
+		t.Errorf("transferChecksum() did not change with content")
+	}
+}
+
+func TestLoadZoneAXFR(t *testing.T) {
+	_, addr, stop := startTestDNSServer(t, "example.com.",
+		"www.example.com. 300 IN A 127.0.0.1",
+		"example.com. 1 IN A 127.0.0.1",
+		"example.com. 300 IN NS ns1.example.com.",
+	)
+	defer stop()
+
+	_, err := parseArguments([]string{"./test", "-tsig-key", "cfzone", "-tsig-secret", testTsigSecret, "axfr://" + addr + "/example.com"})
+	if err != nil {
+		t.Fatalf("parseArguments() returned error: %s", err.Error())
+	}
+
+	zf, err := loadZone("axfr://" + addr + "/example.com")
+	if err != nil {
+		t.Fatalf("loadZone() returned error: %s", err.Error())
+	}
+
+	if zf.Zone.Name != "example.com" || len(zf.Zone.Records) != 2 || len(zf.Checksum) != 32 {
+		t.Fatalf("loadZone() returned wrong zone: %+v", zf.Zone)
+	}
+
+	if !zf.Zone.Records[1].Proxied {
+		t.Errorf("loadZone() did not apply -cachettl to transferred records")
+	}
+
+	tsigSecret = ""
+
+	_, err = loadZone("axfr://" + addr + "/example.com")
+	if err == nil {
+		t.Errorf("loadZone() failed to err without TSIG secret")
+	}
+
+	tsigName = ""
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+type (
+	// cloudflareAPI is the subset of the Cloudflare API needed for managing
+	// records.
+	cloudflareAPI interface {
+		DNSRecords(zoneID string, rr cloudflare.DNSRecord) ([]cloudflare.DNSRecord, error)
+		CreateDNSRecord(zoneID string, rr cloudflare.DNSRecord) (*cloudflare.DNSRecordResponse, error)
+		UpdateDNSRecord(zoneID string, recordID string, rr cloudflare.DNSRecord) error
+		DeleteDNSRecord(zoneID string, recordID string) error
+		ZoneSingleSetting(zoneID, settingName string) (cloudflare.ZoneSetting, error)
+		UpdateZoneSingleSetting(zoneID, settingName string, setting cloudflare.ZoneSetting) (*cloudflare.ZoneSettingSingleResponse, error)
+	}
+
+	// cloudflareProvider is a Provider for a zone hosted by Cloudflare.
+	cloudflareProvider struct {
+		api    cloudflareAPI
+		zoneID string
+	}
+)
+
+// fromCloudflare will convert a Cloudflare record to a Record.
+func fromCloudflare(r cloudflare.DNSRecord) Record {
+	// TXT content can be stored as quoted strings, the content of a Record
+	// is the raw value. This is the only place it's unquoted.
+	if r.Type == "TXT" {
+		if joined, ok := joinTXT(r.Content); ok {
+			r.Content = joined
+		}
+	}
+
+	return Record{
+		ID:       r.ID,
+		Type:     r.Type,
+		Name:     r.Name,
+		Content:  r.Content,
+		TTL:      r.TTL,
+		Priority: r.Priority,
+		Proxied:  r.Proxied,
+	}
+}
+
+// toCloudflare will convert r to a Cloudflare record.
+func toCloudflare(r Record) cloudflare.DNSRecord {
+	if r.Type == "TXT" {
+		r.Content = cloudflareTXT(r.Content)
+	}
+
+	return cloudflare.DNSRecord{
+		ID:       r.ID,
+		Type:     r.Type,
+		Name:     r.Name,
+		Content:  r.Content,
+		TTL:      r.TTL,
+		Priority: r.Priority,
+		Proxied:  r.Proxied,
+	}
+}
+
+// cloudflareTXT will return TXT content as sent to Cloudflare. Content too
+// long for a single string is sent as quoted strings of at most 255 bytes,
+// which Cloudflare stores and serves as is. Content looking like quoted
+// strings is quoted too, or it would be unquoted when read back.
+func cloudflareTXT(content string) string {
+	if _, quoted := joinTXT(content); !quoted && len(content) <= 255 {
+		return content
+	}
+
+	chunks := []string{}
+	for _, chunk := range splitTXT(content) {
+		chunks = append(chunks, quoteTXT(chunk))
+	}
+
+	return strings.Join(chunks, " ")
+}
+
+// newCloudflareConnector will return a connector for zones hosted by
+// Cloudflare using the credentials from the environment.
+func newCloudflareConnector() (connector, error) {
+	api, err := newAPI()
+	if err != nil {
+		return nil, err
+	}
+
+	if apiToken != "" {
+		err = verifyToken(api)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(zoneName string, opts options) (Provider, error) {
+		id, err := resolveZoneID(api, zoneName, opts.ZoneID, opts.AccountID)
+		if err != nil {
+			return nil, fmt.Errorf("Can't get zone ID for '%s': %s", zoneName, err.Error())
+		}
+
+		if apiToken != "" {
+			err = checkPermission(api, id, zoneName)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return &cloudflareProvider{api: api, zoneID: id}, nil
+	}, nil
+}
+
+// Records implements Provider.
+func (p *cloudflareProvider) Records(filter Record) (recordCollection, error) {
+	records, err := p.api.DNSRecords(p.zoneID, cloudflare.DNSRecord{
+		Type: filter.Type,
+		Name: filter.Name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Can't get zone records for '%s': %s", p.zoneID, err.Error())
+	}
+
+	result := make(recordCollection, 0, len(records))
+	for _, r := range records {
+		result = append(result, fromCloudflare(r))
+	}
+
+	return result, nil
+}
+
+// Create implements Provider.
+func (p *cloudflareProvider) Create(r Record) (Record, error) {
+	response, err := p.api.CreateDNSRecord(p.zoneID, toCloudflare(r))
+	if err != nil {
+		return Record{}, err
+	}
+
+	return fromCloudflare(response.Result), nil
+}
+
+// Update implements Provider.
+func (p *cloudflareProvider) Update(r Record) error {
+	return p.api.UpdateDNSRecord(p.zoneID, r.ID, toCloudflare(r))
+}
+
+// Delete implements Provider.
+func (p *cloudflareProvider) Delete(r Record) error {
+	return p.api.DeleteDNSRecord(p.zoneID, r.ID)
+}
+
+// Capabilities implements Provider.
+func (p *cloudflareProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Proxied: true,
+		AutoTTL: true,
+		Types:   []string{"A", "AAAA", "CNAME", "MX", "TXT"},
+
+		// Cloudflare flattens a CNAME at the zone apex.
+		ApexCNAME: true,
+	}
+}
+
+// cfFlatteningSetting is the Cloudflare zone setting for CNAME flattening.
+const cfFlatteningSetting = "cname_flattening"
+
+// cfFlatteningModes maps CNAME flattening modes to the values used by
+// Cloudflare.
+var cfFlatteningModes = map[string]string{
+	flattenRoot: "flatten_at_root",
+	flattenAll:  "flatten_all",
+}
+
+// CNAMEFlattening implements flatteningProvider.
+func (p *cloudflareProvider) CNAMEFlattening() (string, error) {
+	setting, err := p.api.ZoneSingleSetting(p.zoneID, cfFlatteningSetting)
+	if err != nil {
+		return "", fmt.Errorf("Can't get CNAME flattening for '%s': %s", p.zoneID, err.Error())
+	}
+
+	for mode, value := range cfFlatteningModes {
+		if setting.Value == value {
+			return mode, nil
+		}
+	}
+
+	return fmt.Sprintf("%v", setting.Value), nil
+}
+
+// SetCNAMEFlattening implements flatteningProvider.
+func (p *cloudflareProvider) SetCNAMEFlattening(mode string) error {
+	_, err := p.api.UpdateZoneSingleSetting(p.zoneID, cfFlatteningSetting, cloudflare.ZoneSetting{
+		Value: cfFlatteningModes[mode],
+	})
+
+	return err
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// fakeCloudflareAPI is an in-memory implementation of cloudflareAPI.
+type fakeCloudflareAPI struct {
+	records  []cloudflare.DNSRecord
+	nextID   int
+	settings map[string]interface{}
+}
+
+func (f *fakeCloudflareAPI) DNSRecords(zoneID string, rr cloudflare.DNSRecord) ([]cloudflare.DNSRecord, error) {
+	result := []cloudflare.DNSRecord{}
+
+	for _, r := range f.records {
+		if (rr.Type == "" || r.Type == rr.Type) && (rr.Name == "" || r.Name == rr.Name) {
+			result = append(result, r)
+		}
+	}
+
+	return result, nil
+}
+
+func (f *fakeCloudflareAPI) CreateDNSRecord(zoneID string, rr cloudflare.DNSRecord) (*cloudflare.DNSRecordResponse, error) {
+	f.nextID++
+	rr.ID = strconv.Itoa(f.nextID)
+	f.records = append(f.records, rr)
+
+	return &cloudflare.DNSRecordResponse{Result: rr}, nil
+}
+
+func (f *fakeCloudflareAPI) UpdateDNSRecord(zoneID string, recordID string, rr cloudflare.DNSRecord) error {
+	for i, r := range f.records {
+		if r.ID == recordID {
+			f.records[i] = rr
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeCloudflareAPI) DeleteDNSRecord(zoneID string, recordID string) error {
+	for i, r := range f.records {
+		if r.ID == recordID {
+			f.records = append(f.records[:i], f.records[i+1:]...)
+
+			break
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeCloudflareAPI) ZoneSingleSetting(zoneID, settingName string) (cloudflare.ZoneSetting, error) {
+	return cloudflare.ZoneSetting{ID: settingName, Value: f.settings[settingName]}, nil
+}
+
+func (f *fakeCloudflareAPI) UpdateZoneSingleSetting(zoneID, settingName string, setting cloudflare.ZoneSetting) (*cloudflare.ZoneSettingSingleResponse, error) {
+	if f.settings == nil {
+		f.settings = map[string]interface{}{}
+	}
+
+	f.settings[settingName] = setting.Value
+
+	return &cloudflare.ZoneSettingSingleResponse{Result: setting}, nil
+}
+
+func TestCloudflareConversion(t *testing.T) {
+	in := Record{ID: "1", Type: "MX", Name: "example.com", Content: "mx.example.com", TTL: 300, Priority: 10}
+
+	out := fromCloudflare(toCloudflare(in))
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("conversion changed record, got %+v, expected %+v", out, in)
+	}
+}
+
+func TestCloudflareProvider(t *testing.T) {
+	api := &fakeCloudflareAPI{}
+	p := &cloudflareProvider{api: api, zoneID: "z1"}
+
+	created, err := p.Create(Record{Type: "A", Name: "www.example.com", Content: "127.0.0.1", TTL: 300})
+	if err != nil || created.ID == "" {
+		t.Fatalf("Create() returned %+v, %v", created, err)
+	}
+
+	p.Create(Record{Type: "TXT", Name: "example.com", Content: "hello", TTL: 300})
+
+	created.Content = "127.0.0.2"
+	err = p.Update(created)
+	if err != nil {
+		t.Fatalf("Update() returned error: %s", err.Error())
+	}
+
+	records, err := p.Records(Record{Type: "A"})
+	if err != nil || !reflect.DeepEqual(records, recordCollection{created}) {
+		t.Errorf("Records() returned %+v, %v", records, err)
+	}
+
+	err = p.Delete(created)
+	if err != nil || len(api.records) != 1 {
+		t.Errorf("Delete() did not delete the record")
+	}
+}
+
+// testDKIMKey is a 2048-bit DKIM key, too long for a single TXT string.
+var testDKIMKey = "v=DKIM1; k=rsa; p=MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA" + strings.Repeat("q2Hm5Jx8Ld3Kf7Tz", 22) + "IDAQAB"
+
+func TestCloudflareTXT(t *testing.T) {
+	short := Record{Type: "TXT", Name: "example.com", Content: `v=spf1 -all`}
+	if toCloudflare(short).Content != short.Content {
+		t.Errorf("toCloudflare() changed short TXT content to '%s'", toCloudflare(short).Content)
+	}
+
+	long := Record{Type: "TXT", Name: "dkim._domainkey.example.com", Content: testDKIMKey}
+
+	content := toCloudflare(long).Content
+	if !strings.HasPrefix(content, `"v=DKIM1; k=rsa; `) || strings.Count(content, `" "`) != 1 {
+		t.Errorf("toCloudflare() did not split long TXT content, got '%s'", content)
+	}
+
+	out := fromCloudflare(toCloudflare(long))
+	if !reflect.DeepEqual(out, long) {
+		t.Errorf("conversion changed record, got %+v, expected %+v", out, long)
+	}
+}
+
+func TestCloudflareTXTQuotes(t *testing.T) {
+	cases := []string{
+		`v=spf1 -all`,
+		`say "hi"`,
+		`"quoted"`,
+		`"a" "b"`,
+		`"unterminated`,
+		`back\slash`,
+		`"` + testDKIMKey + `"`,
+	}
+
+	for _, in := range cases {
+		r := Record{Type: "TXT", Name: "example.com", Content: in}
+
+		out := fromCloudflare(toCloudflare(r)).Normalize()
+		if out.Content != in {
+			t.Errorf("conversion changed '%s' to '%s' (sent as '%s')", in, out.Content, toCloudflare(r).Content)
+		}
+	}
+
+	// Content of other types is never unquoted.
+	r := fromCloudflare(cloudflare.DNSRecord{Type: "CNAME", Name: "example.com", Content: `"quoted"`})
+	if r.Content != `"quoted"` {
+		t.Errorf("fromCloudflare() unquoted CNAME content to '%s'", r.Content)
+	}
+}
+
+func TestCloudflareTXTNoUpdateLoop(t *testing.T) {
+	// Cloudflare returns the key chunked differently from the zone file.
+	api := &fakeCloudflareAPI{
+		records: []cloudflare.DNSRecord{
+			{ID: "1", Type: "TXT", Name: "dkim._domainkey.example.com", TTL: 300, Content: `"` + testDKIMKey[:100] + `" "` + testDKIMKey[100:] + `"`},
+		},
+		nextID: 1,
+	}
+
+	chunks := []string{}
+	for _, chunk := range splitTXT(testDKIMKey) {
+		chunks = append(chunks, quoteTXT(chunk))
+	}
+
+	zone := "$ORIGIN example.com.\n@ 3600 IN SOA ns1 hostmaster 1 3600 600 86400 300\ndkim._domainkey 300 IN TXT " + strings.Join(chunks, " ") + "\n"
+
+	z, err := parseZoneFile(strings.NewReader(zone), "", cfAutoTTL, cfCacheTTL)
+	if err != nil {
+		t.Fatalf("parseZoneFile() returned error: %s", err.Error())
+	}
+
+	zf := &zoneFile{
+		Paths:   []string{"example.com"},
+		Zone:    z,
+		Options: options{MaxDeletes: -1, MaxChangePercent: -1},
+	}
+
+	connect := fakeConnector(&cloudflareProvider{api: api, zoneID: "z1"})
+
+	for i := 0; i < 2; i++ {
+		p, err := planZone(connect, zf)
+		if err != nil {
+			t.Fatalf("%d: planZone() returned error: %s", i, err.Error())
+		}
+
+		if p.Changes() != 0 {
+			t.Errorf("%d: planZone() found %d changes in the DKIM key", i, p.Changes())
+		}
+
+		err = applyPlans([]*plan{p}, 1)[0]
+		if err != nil {
+			t.Fatalf("%d: applyPlans() returned error: %s", i, err.Error())
+		}
+	}
+}
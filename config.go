@@ -0,0 +1,435 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// defaultConfigFile is the configuration file read if no file is
+	// specified.
+	defaultConfigFile = "cfzone.yaml"
+
+	// defaultProfile is the profile used if no profile is specified.
+	defaultProfile = "default"
+
+	// envPrefix is prepended to the upper-cased flag name to form the name
+	// of the environment variable setting the flag.
+	envPrefix = "CFZONE_"
+)
+
+var (
+	// activeProfile is the profile selected by -profile. activeFlags and
+	// explicitFlags are kept for applying per-zone settings once the zone
+	// name is known.
+	activeProfile = &profile{}
+	activeFlags   *flag.FlagSet
+	explicitFlags = map[string]bool{}
+)
+
+type (
+	// config is the content of a configuration file.
+	config struct {
+		Profiles map[string]*profile `yaml:"profiles"`
+	}
+
+	// profile is a named set of settings.
+	profile struct {
+		// Account is the Cloudflare account ID to use.
+		Account string `yaml:"account"`
+
+		// These mirror the flags of the same name.
+		Provider      string `yaml:"provider"`
+		Server        string `yaml:"server"`
+		TsigKey       string `yaml:"tsig-key"`
+		TsigAlgorithm string `yaml:"tsig-algorithm"`
+
+		Credentials credentials `yaml:"credentials"`
+
+		settings `yaml:",inline"`
+
+		// Zones holds settings overriding the profile settings for a
+		// single zone.
+		Zones map[string]settings `yaml:"zones"`
+	}
+
+	// credentials describes where to find the credentials for a profile.
+	credentials struct {
+		TokenEnv string `yaml:"token-env"`
+		KeyEnv   string `yaml:"key-env"`
+		EmailEnv string `yaml:"email-env"`
+
+		// These mirror the flags of the same name.
+		File    string `yaml:"file"`
+		Command string `yaml:"command"`
+		Netrc   string `yaml:"netrc"`
+	}
+
+	// settings mirrors the command line flags. A nil value is unset.
+	settings struct {
+		AutoTTL          *int     `yaml:"autottl"`
+		CacheTTL         *int     `yaml:"cachettl"`
+		LeaveUnknown     *bool    `yaml:"leaveunknown"`
+		IgnoreSpf        *bool    `yaml:"ignorespf"`
+		IgnoreSrv        *bool    `yaml:"ignoresrv"`
+		Owner            *string  `yaml:"owner"`
+		ZoneID           *string  `yaml:"zone-id"`
+		CNAMEFlattening  *string  `yaml:"cname-flattening"`
+		MaxDeletes       *int     `yaml:"max-deletes"`
+		MaxChangePercent *float64 `yaml:"max-change-percent"`
+
+		// Ignore and Protect are added to the rules from the ignore file
+		// and the -protect flag.
+		Ignore  []string `yaml:"ignore"`
+		Protect []string `yaml:"protect"`
+	}
+)
+
+// parseConfig will parse a configuration file. Unknown keys are rejected to
+// catch typos.
+func parseConfig(r io.Reader) (*config, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &config{}
+
+	err = yaml.UnmarshalStrict(b, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// readConfig will read the configuration file at path. If mustExist is
+// false, a missing file results in an empty configuration.
+func readConfig(path string, mustExist bool) (*config, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) && !mustExist {
+		return &config{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseConfig(f)
+}
+
+// Profile will return the profile called name. If mustExist is false, a
+// missing profile results in an empty profile.
+func (c *config) Profile(name string, mustExist bool) (*profile, error) {
+	p, found := c.Profiles[name]
+	if !found || p == nil {
+		if mustExist {
+			return nil, fmt.Errorf("profile '%s' not found", name)
+		}
+
+		return &profile{}, nil
+	}
+
+	return p, nil
+}
+
+// values will return the settings as flag values indexed by flag name.
+func (s settings) values() map[string]string {
+	values := map[string]string{}
+
+	if s.AutoTTL != nil {
+		values["autottl"] = strconv.Itoa(*s.AutoTTL)
+	}
+
+	if s.CacheTTL != nil {
+		values["cachettl"] = strconv.Itoa(*s.CacheTTL)
+	}
+
+	if s.LeaveUnknown != nil {
+		values["leaveunknown"] = strconv.FormatBool(*s.LeaveUnknown)
+	}
+
+	if s.IgnoreSpf != nil {
+		values["ignorespf"] = strconv.FormatBool(*s.IgnoreSpf)
+	}
+
+	if s.IgnoreSrv != nil {
+		values["ignoresrv"] = strconv.FormatBool(*s.IgnoreSrv)
+	}
+
+	if s.Owner != nil {
+		values["owner"] = *s.Owner
+	}
+
+	if s.ZoneID != nil {
+		values["zone-id"] = *s.ZoneID
+	}
+
+	if s.CNAMEFlattening != nil {
+		values["cname-flattening"] = *s.CNAMEFlattening
+	}
+
+	if s.MaxDeletes != nil {
+		values["max-deletes"] = strconv.Itoa(*s.MaxDeletes)
+	}
+
+	if s.MaxChangePercent != nil {
+		values["max-change-percent"] = strconv.FormatFloat(*s.MaxChangePercent, 'f', -1, 64)
+	}
+
+	return values
+}
+
+// apply will set the flags in flagset from s. Flags present in explicit are
+// left untouched, they have been set on the command line or in the
+// environment. Settings for flags not used by the command are skipped.
+func (s settings) apply(flagset *flag.FlagSet, explicit map[string]bool) error {
+	for name, value := range s.values() {
+		if explicit[name] || flagset.Lookup(name) == nil {
+			continue
+		}
+
+		err := flagset.Set(name, value)
+		if err != nil {
+			return fmt.Errorf("invalid value '%s' for %s: %s", value, name, err.Error())
+		}
+	}
+
+	for _, rule := range s.Protect {
+		if flagset.Lookup("protect") == nil {
+			break
+		}
+
+		err := flagset.Set("protect", rule)
+		if err != nil {
+			return fmt.Errorf("invalid protect rule '%s': %s", rule, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// apply will set the credential flags in flagset from c. Flags present in
+// explicit are left untouched.
+func (c credentials) apply(flagset *flag.FlagSet, explicit map[string]bool) error {
+	values := map[string]string{
+		"credential-file": c.File,
+		"credential-cmd":  c.Command,
+		"netrc":           c.Netrc,
+	}
+
+	for name, value := range values {
+		if value == "" || explicit[name] || flagset.Lookup(name) == nil {
+			continue
+		}
+
+		err := flagset.Set(name, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ignoreRules will return the ignore rules from s.
+func (s settings) ignoreRules() (ignoreRules, error) {
+	rules := ignoreRules{}
+
+	for _, line := range s.Ignore {
+		err := rules.Set(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore rule '%s': %s", line, err.Error())
+		}
+	}
+
+	return rules, nil
+}
+
+// envName will return the name of the environment variable for the flag
+// called name.
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// applyEnv will set all flags not present in explicit from the environment.
+// The flags set will be added to explicit.
+func applyEnv(flagset *flag.FlagSet, explicit map[string]bool) error {
+	var err error
+
+	flagset.VisitAll(func(f *flag.Flag) {
+		if explicit[f.Name] || err != nil {
+			return
+		}
+
+		value, found := os.LookupEnv(envName(f.Name))
+		if !found {
+			return
+		}
+
+		err = flagset.Set(f.Name, value)
+		if err != nil {
+			err = fmt.Errorf("invalid value '%s' for %s: %s", value, envName(f.Name), err.Error())
+		}
+
+		explicit[f.Name] = true
+	})
+
+	return err
+}
+
+// applyCredentials will read the credentials for p from the environment
+// variables named in the profile. Credentials already present in the
+// standard environment variables take precedence.
+func (p *profile) applyCredentials() {
+	if apiToken == "" && p.Credentials.TokenEnv != "" {
+		apiToken = os.Getenv(p.Credentials.TokenEnv)
+	}
+
+	if apiKey == "" && p.Credentials.KeyEnv != "" {
+		apiKey = os.Getenv(p.Credentials.KeyEnv)
+	}
+
+	if apiEmail == "" && p.Credentials.EmailEnv != "" {
+		apiEmail = os.Getenv(p.Credentials.EmailEnv)
+	}
+}
+
+// applyConfig will apply settings from the environment and the selected
+// profile to the flags in flagset. Flags set on the command line take
+// precedence over the environment, which takes precedence over the
+// configuration file.
+func applyConfig(flagset *flag.FlagSet) error {
+	explicit := map[string]bool{}
+	flagset.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	err := applyEnv(flagset, explicit)
+	if err != nil {
+		return err
+	}
+
+	c, err := readConfig(configPath, explicit["config"])
+	if err != nil {
+		return fmt.Errorf("Error reading config '%s': %s", configPath, err.Error())
+	}
+
+	p, err := c.Profile(profileName, explicit["profile"])
+	if err != nil {
+		return fmt.Errorf("Error reading config '%s': %s", configPath, err.Error())
+	}
+
+	err = p.settings.apply(flagset, explicit)
+	if err != nil {
+		return fmt.Errorf("Error in profile '%s': %s", profileName, err.Error())
+	}
+
+	values := map[string]string{
+		"account-id":     p.Account,
+		"provider":       p.Provider,
+		"server":         p.Server,
+		"tsig-key":       p.TsigKey,
+		"tsig-algorithm": p.TsigAlgorithm,
+	}
+
+	for name, value := range values {
+		if value == "" || explicit[name] || flagset.Lookup(name) == nil {
+			continue
+		}
+
+		err = flagset.Set(name, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = p.Credentials.apply(flagset, explicit)
+	if err != nil {
+		return fmt.Errorf("Error in profile '%s': %s", profileName, err.Error())
+	}
+
+	p.applyCredentials()
+
+	activeProfile = p
+	activeFlags = flagset
+	explicitFlags = explicit
+
+	return nil
+}
+
+// applyZoneConfig will apply the settings for zoneName from the selected
+// profile. It returns true if any settings were found for the zone.
+func applyZoneConfig(zoneName string) (bool, error) {
+	s, found := activeProfile.Zones[zoneName]
+	if !found || activeFlags == nil {
+		return false, nil
+	}
+
+	err := s.apply(activeFlags, explicitFlags)
+	if err != nil {
+		return true, fmt.Errorf("Error in profile '%s' for zone '%s': %s", profileName, zoneName, err.Error())
+	}
+
+	return true, nil
+}
+
+// withZoneConfig will apply the settings for zoneName and call fn. The
+// settings in effect before are restored when fn returns, so settings for
+// one zone never leak into another. Lists are restored from copies, setting
+// them again would add to them.
+func withZoneConfig(zoneName string, fn func(found bool) error) error {
+	saved := map[string]string{}
+	savedProtect := append(ignoreRules{}, protectRules...)
+	savedAllowNotify := append(networkList{}, allowNotify...)
+
+	if activeFlags != nil {
+		activeFlags.VisitAll(func(f *flag.Flag) {
+			saved[f.Name] = f.Value.String()
+		})
+	}
+
+	defer func() {
+		for name, value := range saved {
+			if name == "protect" || name == "allow-notify" {
+				continue
+			}
+
+			activeFlags.Set(name, value)
+		}
+
+		protectRules = savedProtect
+		allowNotify = savedAllowNotify
+	}()
+
+	found, err := applyZoneConfig(zoneName)
+	if err != nil {
+		return err
+	}
+
+	return fn(found)
+}
+
+// configIgnoreRules will return the ignore rules from the selected profile
+// for zoneName.
+func configIgnoreRules(zoneName string) (ignoreRules, error) {
+	rules, err := activeProfile.ignoreRules()
+	if err != nil {
+		return nil, fmt.Errorf("Error in profile '%s': %s", profileName, err.Error())
+	}
+
+	zoneRules, err := activeProfile.Zones[zoneName].ignoreRules()
+	if err != nil {
+		return nil, fmt.Errorf("Error in profile '%s' for zone '%s': %s", profileName, zoneName, err.Error())
+	}
+
+	return append(rules, zoneRules...), nil
+}
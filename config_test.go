@@ -0,0 +1,238 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testConfig = `
+profiles:
+  default:
+    owner: default-owner
+
+  prod:
+    account: 0123456789abcdef
+    credentials:
+      token-env: CFZONE_TEST_TOKEN
+    autottl: 300
+    cachettl: 2
+    owner: prod
+    leaveunknown: true
+    max-deletes: 10
+    ignore:
+      - _acme-challenge.*
+    protect:
+      - type=MX
+    zones:
+      example.com:
+        autottl: 600
+        cname-flattening: all
+        ignore:
+          - type=TXT content=google-site-verification*
+`
+
+func writeTestConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+
+	path := filepath.Join(dir, "cfzone.yaml")
+
+	err = ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile() failed: %s", err.Error())
+	}
+
+	return path
+}
+
+func TestParseConfig(t *testing.T) {
+	c, err := parseConfig(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatalf("parseConfig() returned error: %s", err.Error())
+	}
+
+	p, err := c.Profile("prod", true)
+	if err != nil {
+		t.Fatalf("Profile() returned error: %s", err.Error())
+	}
+
+	if p.Account != "0123456789abcdef" || *p.AutoTTL != 300 || *p.Zones["example.com"].AutoTTL != 600 {
+		t.Errorf("parseConfig() returned wrong profile: %+v", p)
+	}
+
+	_, err = c.Profile("staging", true)
+	if err == nil {
+		t.Errorf("Profile() failed to err on missing profile")
+	}
+
+	p, err = c.Profile("staging", false)
+	if err != nil || p == nil {
+		t.Errorf("Profile() did not return empty profile for missing optional profile")
+	}
+
+	_, err = parseConfig(strings.NewReader("profiles:\n  prod:\n    autotll: 300\n"))
+	if err == nil {
+		t.Errorf("parseConfig() failed to err on unknown key")
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := writeTestConfig(t, testConfig)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	os.Setenv("CFZONE_TEST_TOKEN", "secret")
+	os.Setenv("CFZONE_CACHETTL", "3")
+	defer os.Unsetenv("CFZONE_TEST_TOKEN")
+	defer os.Unsetenv("CFZONE_CACHETTL")
+
+	apiToken = ""
+
+	_, err := parseArguments([]string{"./test", "-config", path, "-profile", "prod", "-owner", "flag", "zone"})
+	if err != nil {
+		t.Fatalf("parseArguments() returned error: %s", err.Error())
+	}
+
+	if owner != "flag" {
+		t.Errorf("flag did not take precedence over profile, owner is '%s'", owner)
+	}
+
+	if zoneCacheTTL != 3 {
+		t.Errorf("environment did not take precedence over profile, cachettl is %d", zoneCacheTTL)
+	}
+
+	if zoneAutoTTL != 300 || !leaveUnknown || maxDeletes != 10 || accountID != "0123456789abcdef" {
+		t.Errorf("profile settings not applied")
+	}
+
+	if apiToken != "secret" {
+		t.Errorf("profile credentials not applied")
+	}
+
+	if len(protectRules) != 1 {
+		t.Errorf("profile protect rules not applied, got %d rules", len(protectRules))
+	}
+
+	found, err := applyZoneConfig("example.com")
+	if !found || err != nil {
+		t.Fatalf("applyZoneConfig() did not apply zone settings")
+	}
+
+	if zoneAutoTTL != 600 || cnameFlattening != flattenAll {
+		t.Errorf("zone settings not applied, autottl is %d, cname-flattening is '%s'", zoneAutoTTL, cnameFlattening)
+	}
+
+	rules, err := configIgnoreRules("example.com")
+	if err != nil || len(rules) != 2 {
+		t.Errorf("configIgnoreRules() returned wrong rules: %+v, %v", rules, err)
+	}
+
+	apiToken = ""
+}
+
+func TestConfigMissing(t *testing.T) {
+	_, err := parseArguments([]string{"./test", "-config", "/non/existing/cfzone.yaml", "zone"})
+	if err == nil {
+		t.Errorf("parseArguments() did not err on missing config file")
+	}
+
+	path := writeTestConfig(t, testConfig)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	_, err = parseArguments([]string{"./test", "-config", path, "-profile", "staging", "zone"})
+	if err == nil {
+		t.Errorf("parseArguments() did not err on missing profile")
+	}
+
+	_, err = parseArguments([]string{"./test", "-config", path, "zone"})
+	if err != nil {
+		t.Errorf("parseArguments() returned error for default profile: %s", err.Error())
+	}
+
+	if owner != "default-owner" {
+		t.Errorf("default profile not applied, owner is '%s'", owner)
+	}
+}
+
+func TestWithZoneConfig(t *testing.T) {
+	path := writeTestConfig(t, testConfig)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	_, err := parseArguments([]string{"./test", "-config", path, "-profile", "prod", "zone"})
+	if err != nil {
+		t.Fatalf("parseArguments() returned error: %s", err.Error())
+	}
+
+	err = withZoneConfig("example.com", func(found bool) error {
+		if !found || zoneAutoTTL != 600 {
+			t.Errorf("withZoneConfig() did not apply zone settings, autottl is %d", zoneAutoTTL)
+		}
+
+		protectRules = append(protectRules, ignoreRule{Type: "NS"})
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withZoneConfig() returned error: %s", err.Error())
+	}
+
+	if zoneAutoTTL != 300 || owner != "prod" {
+		t.Errorf("withZoneConfig() did not restore settings, autottl is %d", zoneAutoTTL)
+	}
+
+	if len(protectRules) != 1 {
+		t.Errorf("withZoneConfig() did not restore protect rules, got %d rules", len(protectRules))
+	}
+
+	err = withZoneConfig("example.net", func(found bool) error {
+		if found {
+			t.Errorf("withZoneConfig() found settings for unknown zone")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Errorf("withZoneConfig() returned error: %s", err.Error())
+	}
+}
+
+func TestWithZoneConfigLists(t *testing.T) {
+	defer func(n networkList, p ignoreRules, o string, a, c, d int, l bool, tok string) {
+		allowNotify, protectRules, owner = n, p, o
+		zoneAutoTTL, zoneCacheTTL, maxDeletes, leaveUnknown, apiToken = a, c, d, l, tok
+	}(allowNotify, protectRules, owner, zoneAutoTTL, zoneCacheTTL, maxDeletes, leaveUnknown, apiToken)
+	defer func(p *profile, f *flag.FlagSet, e map[string]bool) {
+		activeProfile, activeFlags, explicitFlags = p, f, e
+	}(activeProfile, activeFlags, explicitFlags)
+
+	path := writeTestConfig(t, testConfig)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	zone := filepath.Join(filepath.Dir(path), "example.com")
+	ioutil.WriteFile(zone, []byte("$ORIGIN example.com.\n@ 300 IN SOA ns1 hostmaster 1 3600 600 86400 300\nwww 300 IN A 127.0.0.1\n"), 0600)
+
+	_, err := parseArguments([]string{"./test", "-config", path, "-profile", "prod", "-allow-notify", "192.0.2.0/24", zone}, serveFlags)
+	if err != nil {
+		t.Fatalf("parseArguments() returned error: %s", err.Error())
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = loadZone(zone)
+		if err != nil {
+			t.Fatalf("loadZone() returned error: %s", err.Error())
+		}
+	}
+
+	if allowNotify.String() != "192.0.2.0/24" {
+		t.Errorf("loadZone() changed the allowed networks to '%s'", allowNotify.String())
+	}
+
+	if len(protectRules) != 1 {
+		t.Errorf("loadZone() changed the protect rules, got %d rules", len(protectRules))
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+	"gopkg.in/yaml.v2"
+)
+
+// bindFormat is the BIND zone file format for "cfzone convert".
+const bindFormat = "bind"
+
+// toStructured will convert r in the zone called zoneName to a record in
+// the structured zone format. The TTL and proxying are always given.
+func toStructured(r Record, zoneName string, protected bool) structuredRecord {
+	s := structuredRecord{
+		Name:    r.Name + ".",
+		Type:    r.Type,
+		Content: r.Content,
+		Comment: r.Comment,
+		Tags:    r.Tags,
+	}
+
+	switch {
+	case r.Name == zoneName:
+		s.Name = "@"
+
+	case strings.HasSuffix(r.Name, "."+zoneName):
+		s.Name = strings.TrimSuffix(r.Name, "."+zoneName)
+	}
+
+	switch {
+	case r.Proxied:
+		s.Proxied = true
+
+	case r.TTL == cfAutoTTL:
+		s.TTL = &structuredTTL{Auto: true}
+
+	default:
+		s.TTL = &structuredTTL{Seconds: r.TTL}
+	}
+
+	if r.Type == "MX" {
+		priority := r.Priority
+		s.Priority = &priority
+	}
+
+	if protected && !strings.Contains(s.Comment, protectAnnotation) {
+		s.Comment = strings.TrimSpace(s.Comment + " " + protectAnnotation)
+	}
+
+	return s
+}
+
+// isProtected will return true if r is among the protected records of z.
+func (z *parsedZone) isProtected(r Record) bool {
+	n, _ := z.Protected.Find(r, FullMatch)
+
+	return n >= 0
+}
+
+// writeStructuredZone will write z to w in the structured zone format.
+func writeStructuredZone(w io.Writer, z *parsedZone, format string) error {
+	s := structuredZone{
+		Zone:    z.Name,
+		Records: []structuredRecord{},
+	}
+
+	for _, r := range z.Records {
+		s.Records = append(s.Records, toStructured(r, z.Name, z.isProtected(r)))
+	}
+
+	var b []byte
+	var err error
+
+	switch format {
+	case yamlFormat:
+		b, err = yaml.Marshal(s)
+
+	case jsonFormat:
+		b, err = json.MarshalIndent(s, "", "  ")
+		b = append(b, '\n')
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+
+	return err
+}
+
+// writeBINDZone will write z to w as a BIND zone file using the TTLs given
+// by -autottl and -cachettl for automatic TTL and proxied records. The
+// problems preventing an exact conversion are returned.
+func writeBINDZone(w io.Writer, z *parsedZone) []string {
+	problems := []string{}
+
+	fmt.Fprintf(w, "; Zone %s converted by cfzone.\n", z.Name)
+
+	if zoneCacheTTL >= 0 {
+		fmt.Fprintf(w, "; Records with TTL %d are proxied (-cachettl %d).\n", zoneCacheTTL, zoneCacheTTL)
+	}
+
+	if zoneAutoTTL >= 0 {
+		fmt.Fprintf(w, "; Records with TTL %d use automatic TTL (-autottl %d).\n", zoneAutoTTL, zoneAutoTTL)
+	}
+
+	fmt.Fprintf(w, "$ORIGIN %s\n", dns.Fqdn(z.Name))
+	fmt.Fprintf(w, "@ 3600 IN SOA ns.invalid. hostmaster.invalid. 1 3600 600 86400 300 ; Only names the zone\n")
+
+	for _, r := range z.Records {
+		s := toStructured(r, z.Name, z.isProtected(r))
+		problem := func(format string, a ...interface{}) {
+			problems = append(problems, fmt.Sprintf("%s %s: ", r.Name, r.Type)+fmt.Sprintf(format, a...))
+		}
+
+		comments := []string{}
+
+		ttl := r.TTL
+		switch {
+		case r.Proxied && zoneCacheTTL < 0:
+			problem("proxied records need -cachettl, not converted")
+
+			continue
+
+		case r.Proxied:
+			ttl = zoneCacheTTL
+			comments = append(comments, "PROXIED")
+
+		case r.TTL == cfAutoTTL && zoneAutoTTL < 0:
+			problem("automatic TTL needs -autottl, not converted")
+
+			continue
+
+		case r.TTL == cfAutoTTL:
+			ttl = zoneAutoTTL
+			comments = append(comments, "AUTO TTL")
+
+		case r.TTL == zoneCacheTTL || r.TTL == zoneAutoTTL:
+			problem("TTL %d is reserved by -autottl or -cachettl, not converted", r.TTL)
+
+			continue
+		}
+
+		if s.Comment != "" {
+			if strings.ContainsAny(s.Comment, "\r\n") {
+				problem("comment spans several lines, joined into one")
+			}
+
+			comments = append(comments, strings.Join(strings.Fields(s.Comment), " "))
+		}
+
+		if len(s.Tags) > 0 {
+			problem("tags can't be represented in BIND zone files, written as a comment")
+			comments = append(comments, "tags: "+strings.Join(s.Tags, ","))
+		}
+
+		// The zone file sets $ORIGIN, targets must be absolute.
+		if r.Type == "CNAME" || r.Type == "MX" {
+			s.Content = dns.Fqdn(s.Content)
+		}
+
+		rdata, err := s.rdata(r.Type)
+		if err != nil {
+			problem("%s, not converted", err.Error())
+
+			continue
+		}
+
+		comment := ""
+		if len(comments) > 0 {
+			comment = " ; " + strings.Join(comments, ", ")
+		}
+
+		fmt.Fprintf(w, "%s %d IN %s %s%s\n", s.Name, ttl, r.Type, rdata, comment)
+	}
+
+	return problems
+}
+
+// convertCommand implements "cfzone convert". It will convert a zone file
+// between the BIND and the structured zone formats.
+func convertCommand(args []string) {
+	format := ""
+
+	flagset := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flagset.Usage = func() {
+		fmt.Fprintf(flagset.Output(), "Usage of %s convert [flags] input-file [output-file]:\n", os.Args[0])
+		flagset.PrintDefaults()
+	}
+	flagset.SetOutput(stderr)
+	flagset.StringVar(&format, "format", "", "Convert to `format` (bind, yaml or json), defaults to the format of output-file")
+	flagset.StringVar(&origin, "origin", "", "Specify origin to resolve '@' at the top level")
+	flagset.IntVar(&zoneAutoTTL, "autottl", 0, "Specify TTL to interpret as Cloudflare automatic")
+	flagset.IntVar(&zoneCacheTTL, "cachettl", 1, "Specify TTL to interpret as Cloudflare caching")
+
+	err := flagset.Parse(args[1:])
+	if err == nil && (flagset.NArg() < 1 || flagset.NArg() > 2) {
+		err = errors.New("Input file must be specified")
+		fmt.Fprintln(flagset.Output(), err)
+		flagset.Usage()
+	}
+
+	if err != nil {
+		exit(1)
+	}
+
+	input := flagset.Arg(0)
+	output := flagset.Arg(1)
+	inputFormat := structuredFormat(input)
+
+	// BIND zone files are converted to YAML and structured zones to BIND,
+	// unless the output file says otherwise.
+	if format == "" {
+		format = structuredFormat(output)
+	}
+
+	if format == "" && inputFormat == "" {
+		format = yamlFormat
+	}
+
+	if format == "" {
+		format = bindFormat
+	}
+
+	if format != bindFormat && format != yamlFormat && format != jsonFormat {
+		fmt.Fprintf(stderr, "Unknown format '%s'\n", format)
+		exit(1)
+	}
+
+	b, err := ioutil.ReadFile(input)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error opening '%s': %s\n", input, err.Error())
+		exit(1)
+	}
+
+	var zone *parsedZone
+	if inputFormat != "" {
+		zone, err = parseStructuredZone(b, inputFormat, origin)
+	} else {
+		zone, err = parseZoneFile(bytes.NewReader(b), origin, zoneAutoTTL, zoneCacheTTL)
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "Error reading '%s': %s\n", input, err.Error())
+		exit(1)
+	}
+
+	problems := []string{}
+	for _, rr := range zone.Skipped {
+		// The SOA record only names the zone.
+		if rr.Header().Rrtype == dns.TypeSOA {
+			continue
+		}
+
+		problems = append(problems, fmt.Sprintf("%s %s: ignored by cfzone, not converted", strings.Trim(rr.Header().Name, "."), dns.TypeToString[rr.Header().Rrtype]))
+	}
+
+	out := &bytes.Buffer{}
+	if format == bindFormat {
+		problems = append(problems, writeBINDZone(out, zone)...)
+	} else {
+		err = writeStructuredZone(out, zone, format)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s\n", err.Error())
+			exit(1)
+		}
+	}
+
+	if output == "" {
+		stdout.Write(out.Bytes())
+	} else {
+		err = ioutil.WriteFile(output, out.Bytes(), 0644)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error writing '%s': %s\n", output, err.Error())
+			exit(1)
+		}
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintf(stderr, "%s\n", problem)
+	}
+
+	if len(problems) > 0 {
+		exit(1)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testConvertBIND = `$ORIGIN example.com.
+@ 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 3600 600 86400 300
+@ 3600 IN NS ns1.example.com.
+@ 300 IN A 127.0.0.1 ; cfzone:protected
+www 1 IN CNAME example.com.
+mail 0 IN A 127.0.0.2 ; mail server
+@ 300 IN MX 10 mail.example.com.
+txt 300 IN TXT "say \"hi\" \\o/" "and more"
+other.example.net. 300 IN A 127.0.0.3
+`
+
+func TestConvertRoundTrip(t *testing.T) {
+	defer func(autoTTL, cacheTTL int) { zoneAutoTTL, zoneCacheTTL = autoTTL, cacheTTL }(zoneAutoTTL, zoneCacheTTL)
+	zoneAutoTTL, zoneCacheTTL = 0, 1
+
+	z, err := parseZoneFile(strings.NewReader(testConvertBIND), "", cfAutoTTL, cfCacheTTL)
+	if err != nil {
+		t.Fatalf("parseZoneFile() returned error: %s", err.Error())
+	}
+
+	// Lines are only known for BIND zone files.
+	for i := range z.Records {
+		z.Records[i].Line = 0
+	}
+
+	for i := range z.Protected {
+		z.Protected[i].Line = 0
+	}
+
+	for _, format := range []string{yamlFormat, jsonFormat} {
+		structured := &bytes.Buffer{}
+
+		err = writeStructuredZone(structured, z, format)
+		if err != nil {
+			t.Fatalf("%s: writeStructuredZone() returned error: %s", format, err.Error())
+		}
+
+		z2, err := parseStructuredZone(structured.Bytes(), format, "")
+		if err != nil {
+			t.Fatalf("%s: parseStructuredZone() returned error: %s\n%s", format, err.Error(), structured.String())
+		}
+
+		if z2.Name != z.Name || !reflect.DeepEqual(z2.Records, z.Records) || !reflect.DeepEqual(z2.Protected, z.Protected) {
+			t.Errorf("%s: conversion changed zone, got:\n%s, expected:\n%s", format, zoneString(z2.Records), zoneString(z.Records))
+		}
+
+		bind := &bytes.Buffer{}
+
+		problems := writeBINDZone(bind, z2)
+		if len(problems) != 0 {
+			t.Errorf("%s: writeBINDZone() returned problems: %v", format, problems)
+		}
+
+		z3, err := parseZoneFile(bind, "", cfAutoTTL, cfCacheTTL)
+		if err != nil {
+			t.Fatalf("%s: parseZoneFile() returned error: %s", format, err.Error())
+		}
+
+		for i := range z3.Records {
+			// Comments gain the mapping of the TTL.
+			z3.Records[i].Comment = z.Records[i].Comment
+			z3.Records[i].Line = 0
+		}
+
+		if !reflect.DeepEqual(z3.Records, z.Records) || len(z3.Protected) != 1 {
+			t.Errorf("%s: conversion changed zone, got:\n%s, expected:\n%s", format, zoneString(z3.Records), zoneString(z.Records))
+		}
+	}
+}
+
+func TestWriteBINDZoneProblems(t *testing.T) {
+	defer func(autoTTL, cacheTTL int) { zoneAutoTTL, zoneCacheTTL = autoTTL, cacheTTL }(zoneAutoTTL, zoneCacheTTL)
+
+	z := &parsedZone{
+		Name: "example.com",
+		Records: recordCollection{
+			{Type: "A", Name: "tags.example.com", Content: "127.0.0.1", TTL: 300, Tags: []string{"web"}},
+			{Type: "A", Name: "comment.example.com", Content: "127.0.0.1", TTL: 300, Comment: "two\nlines"},
+			{Type: "A", Name: "proxied.example.com", Content: "127.0.0.1", TTL: cfCacheTTL, Proxied: true},
+			{Type: "A", Name: "auto.example.com", Content: "127.0.0.1", TTL: cfAutoTTL},
+			{Type: "A", Name: "reserved.example.com", Content: "127.0.0.1", TTL: 60},
+		},
+	}
+
+	zoneAutoTTL, zoneCacheTTL = -1, 60
+
+	out := &bytes.Buffer{}
+	problems := writeBINDZone(out, z)
+
+	expected := []string{
+		"tags.example.com A: tags can't be represented in BIND zone files, written as a comment",
+		"comment.example.com A: comment spans several lines, joined into one",
+		"auto.example.com A: automatic TTL needs -autottl, not converted",
+		"reserved.example.com A: TTL 60 is reserved by -autottl or -cachettl, not converted",
+	}
+
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("writeBINDZone() returned wrong problems, got %q", problems)
+	}
+
+	for _, line := range []string{"tags 300 IN A 127.0.0.1 ; tags: web\n", "comment 300 IN A 127.0.0.1 ; two lines\n", "proxied 60 IN A 127.0.0.1 ; PROXIED\n"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("writeBINDZone() did not write '%s', got:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestConvertCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	zonePath := filepath.Join(dir, "example.com.zone")
+	jsonPath := filepath.Join(dir, "example.com.json")
+	bindPath := filepath.Join(dir, "example.com.bind")
+
+	ioutil.WriteFile(zonePath, []byte(testStructuredBIND), 0600)
+
+	convertCommand([]string{"convert", zonePath, jsonPath})
+	convertCommand([]string{"convert", "-format", "bind", jsonPath, bindPath})
+
+	b, _ := ioutil.ReadFile(bindPath)
+	if !strings.Contains(string(b), "www 1 IN CNAME example.com. ; PROXIED\n") {
+		t.Errorf("convertCommand() wrote wrong zone file:\n%s", b)
+	}
+}
+
+func TestConvertCommandProblems(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	zonePath := filepath.Join(dir, "example.com.zone")
+	ioutil.WriteFile(zonePath, []byte(testConvertBIND), 0600)
+
+	defer expectExit(t, 1)
+
+	// The NS record can't be converted.
+	convertCommand([]string{"convert", zonePath, filepath.Join(dir, "example.com.yaml")})
+}
+
+func TestConvertCommandErrors(t *testing.T) {
+	cases := [][]string{
+		{"convert"},
+		{"convert", "a", "b", "c"},
+		{"convert", "-format", "toml", "example.com.zone"},
+		{"convert", "/nonexistent/example.com.zone"},
+	}
+
+	for _, in := range cases {
+		func() {
+			defer expectExit(t, 1)
+
+			convertCommand(in)
+		}()
+	}
+}
+
+func TestConvertCommandSkipped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	defer func() { stderr = os.Stderr }()
+	out := &bytes.Buffer{}
+	stderr = out
+
+	yamlPath := filepath.Join(dir, "example.com.yaml")
+	bindPath := filepath.Join(dir, "example.com.zone")
+
+	ioutil.WriteFile(yamlPath, []byte("zone: example.com\nrecords:\n  - {name: '@', type: NS, content: ns1.example.com., ttl: 3600}\n  - {name: www, type: A, content: 127.0.0.1, ttl: 300}\n"), 0600)
+
+	func() {
+		defer expectExit(t, 1)
+
+		convertCommand([]string{"convert", yamlPath, bindPath})
+	}()
+
+	if !strings.Contains(out.String(), "example.com NS: ignored by cfzone, not converted\n") {
+		t.Errorf("convertCommand() did not report the NS record, got:\n%s", out.String())
+	}
+
+	b, _ := ioutil.ReadFile(bindPath)
+	if !strings.Contains(string(b), "www 300 IN A 127.0.0.1\n") {
+		t.Errorf("convertCommand() wrote wrong zone file:\n%s", b)
+	}
+}
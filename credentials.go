@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// cloudflareHost is the host credentials are looked up for in netrc files
+// and credential helpers.
+const cloudflareHost = "api.cloudflare.com"
+
+// redacted replaces secrets in output.
+const redacted = "[REDACTED]"
+
+// secret holds either an API token or a global API key and email.
+type secret struct {
+	Token string
+	Key   string
+	Email string
+}
+
+// redactingWriter replaces secrets in everything written to it.
+type redactingWriter struct {
+	w       io.Writer
+	secrets []string
+}
+
+// Write implements io.Writer.
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	s := string(p)
+	for _, secret := range r.secrets {
+		s = strings.Replace(s, secret, redacted, -1)
+	}
+
+	_, err := io.WriteString(r.w, s)
+
+	return len(p), err
+}
+
+// redactOutput will make sure the credentials in use are never written to
+// stdout or stderr.
+func redactOutput() {
+	secrets := []string{}
+	for _, s := range []string{apiToken, apiKey, tsigSecret} {
+		if s != "" {
+			secrets = append(secrets, s)
+		}
+	}
+
+	if len(secrets) == 0 {
+		return
+	}
+
+	stdout = &redactingWriter{w: stdout, secrets: secrets}
+	stderr = &redactingWriter{w: stderr, secrets: secrets}
+}
+
+// readSecretFile will read an API token from the file at path. Surrounding
+// whitespace is removed, Docker and Kubernetes secrets often end in a
+// newline.
+func readSecretFile(path string) (secret, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return secret{}, err
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return secret{}, errors.New("file is empty")
+	}
+
+	return secret{Token: token}, nil
+}
+
+// parseCredentialOutput will parse the output of a credential helper. The
+// output is in the git-credential format, one key=value pair per line. A
+// password without a username is an API token, a password with a username
+// is a global API key and the email address.
+func parseCredentialOutput(r io.Reader) (secret, error) {
+	var username, password string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch kv[0] {
+		case "username":
+			username = kv[1]
+		case "password":
+			password = kv[1]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return secret{}, err
+	}
+
+	if password == "" {
+		return secret{}, errors.New("no password returned")
+	}
+
+	if username == "" {
+		return secret{Token: password}, nil
+	}
+
+	return secret{Key: password, Email: username}, nil
+}
+
+// runCredentialCommand will run command using the shell, like git does for
+// credential helpers. The command is given the argument "get" and the
+// request on stdin.
+func runCredentialCommand(command string) (secret, error) {
+	cmd := exec.Command("sh", "-c", command+" get")
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=" + cloudflareHost + "\n\n")
+	cmd.Stderr = stderr
+
+	// The output is never included in errors, it could hold the secret.
+	out, err := cmd.Output()
+	if err != nil {
+		return secret{}, err
+	}
+
+	return parseCredentialOutput(bytes.NewReader(out))
+}
+
+// netrcTokens will split a netrc file into tokens. Macro definitions are
+// skipped.
+func netrcTokens(r io.Reader) ([]string, error) {
+	tokens := []string{}
+	inMacro := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		// Macro definitions end at the first empty line.
+		if inMacro {
+			inMacro = len(fields) > 0
+
+			continue
+		}
+
+		for i, field := range fields {
+			if field == "macdef" {
+				inMacro = true
+				fields = fields[:i]
+
+				break
+			}
+		}
+
+		tokens = append(tokens, fields...)
+	}
+
+	return tokens, scanner.Err()
+}
+
+// parseNetrc will find the credentials for machine in a netrc file. The
+// login is the email address for a global API key, without a login the
+// password is an API token.
+func parseNetrc(r io.Reader, machine string) (secret, error) {
+	var login, password string
+	var current bool
+
+	tokens, err := netrcTokens(r)
+	if err != nil {
+		return secret{}, err
+	}
+
+tokens:
+	for i := 0; i < len(tokens); i++ {
+		var next string
+		if i+1 < len(tokens) {
+			next = tokens[i+1]
+		}
+
+		switch tokens[i] {
+		case "machine":
+			if current {
+				break tokens
+			}
+
+			current = next == machine
+			i++
+
+		case "default":
+			if current {
+				break tokens
+			}
+
+			current = true
+
+		case "login":
+			if current {
+				login = next
+			}
+			i++
+
+		case "password":
+			if current {
+				password = next
+			}
+			i++
+
+		case "account":
+			i++
+		}
+	}
+
+	if password == "" {
+		return secret{}, fmt.Errorf("no password found for %s", machine)
+	}
+
+	if login == "" {
+		return secret{Token: password}, nil
+	}
+
+	return secret{Key: password, Email: login}, nil
+}
+
+// readNetrc will find the credentials for Cloudflare in the netrc file at
+// path.
+func readNetrc(path string) (secret, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return secret{}, err
+	}
+	defer f.Close()
+
+	return parseNetrc(f, cloudflareHost)
+}
+
+// loadCredentials will read the credentials from the sources given by flags
+// or the selected profile. Sources set by flags or in the environment take
+// precedence over credentials from environment variables, which take
+// precedence over sources from the configuration file.
+func loadCredentials() error {
+	haveEnv := apiToken != "" || (apiKey != "" && apiEmail != "")
+
+	// The name is used in errors instead of the value, a command could
+	// include a secret.
+	sources := []struct {
+		name  string
+		flag  string
+		value string
+		load  func(string) (secret, error)
+	}{
+		{"-credential-file", "credential-file", credentialFile, readSecretFile},
+		{"-credential-cmd", "credential-cmd", credentialCmd, runCredentialCommand},
+		{"-netrc", "netrc", netrcPath, readNetrc},
+		{"CF_API_TOKEN_FILE", "", os.Getenv("CF_API_TOKEN_FILE"), readSecretFile},
+	}
+
+	for _, source := range sources {
+		if source.value == "" {
+			continue
+		}
+
+		if haveEnv && !explicitFlags[source.flag] {
+			continue
+		}
+
+		s, err := source.load(source.value)
+		if err != nil {
+			return fmt.Errorf("Can't read credentials from %s: %s", source.name, err.Error())
+		}
+
+		apiToken, apiKey, apiEmail = s.Token, s.Key, s.Email
+
+		break
+	}
+
+	redactOutput()
+
+	return nil
+}
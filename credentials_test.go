@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactingWriter(t *testing.T) {
+	var b bytes.Buffer
+
+	w := &redactingWriter{w: &b, secrets: []string{"s3cr3t", "k3y"}}
+
+	in := "Failed to add record {Content:s3cr3t}: invalid key k3y\n"
+
+	n, err := w.Write([]byte(in))
+	if err != nil || n != len(in) {
+		t.Fatalf("Write() returned %d, %v", n, err)
+	}
+
+	expected := "Failed to add record {Content:[REDACTED]}: invalid key [REDACTED]\n"
+	if b.String() != expected {
+		t.Errorf("Write() did not redact secrets, got '%s', expected '%s'", b.String(), expected)
+	}
+}
+
+func TestReadSecretFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token")
+	ioutil.WriteFile(path, []byte("token123\n"), 0600)
+
+	s, err := readSecretFile(path)
+	if err != nil || s.Token != "token123" {
+		t.Errorf("readSecretFile() returned %+v, %v", s, err)
+	}
+
+	empty := filepath.Join(dir, "empty")
+	ioutil.WriteFile(empty, []byte("\n"), 0600)
+
+	_, err = readSecretFile(empty)
+	if err == nil {
+		t.Errorf("readSecretFile() failed to err on empty file")
+	}
+
+	_, err = readSecretFile(filepath.Join(dir, "missing"))
+	if err == nil {
+		t.Errorf("readSecretFile() failed to err on missing file")
+	}
+}
+
+func TestParseCredentialOutput(t *testing.T) {
+	cases := []struct {
+		in       string
+		expected secret
+		err      bool
+	}{
+		{"password=token123\n", secret{Token: "token123"}, false},
+		{"protocol=https\nhost=api.cloudflare.com\nusername=user@example.com\npassword=key123\n", secret{Key: "key123", Email: "user@example.com"}, false},
+		{"password=a=b\n", secret{Token: "a=b"}, false},
+		{"username=user@example.com\n", secret{}, true},
+		{"", secret{}, true},
+	}
+
+	for i, in := range cases {
+		s, err := parseCredentialOutput(strings.NewReader(in.in))
+		if in.err && err == nil {
+			t.Errorf("%d: parseCredentialOutput() failed to err", i)
+		}
+
+		if !in.err && err != nil {
+			t.Errorf("%d: parseCredentialOutput() returned error: %s", i, err.Error())
+		}
+
+		if s != in.expected {
+			t.Errorf("%d: parseCredentialOutput() returned %+v, expected %+v", i, s, in.expected)
+		}
+	}
+}
+
+func TestRunCredentialCommand(t *testing.T) {
+	s, err := runCredentialCommand("printf 'password=token123\\n'; true")
+	if err != nil || s.Token != "token123" {
+		t.Errorf("runCredentialCommand() returned %+v, %v", s, err)
+	}
+
+	_, err = runCredentialCommand("false")
+	if err == nil {
+		t.Errorf("runCredentialCommand() failed to err on failing command")
+	}
+}
+
+func TestParseNetrc(t *testing.T) {
+	cases := []struct {
+		in       string
+		expected secret
+		err      bool
+	}{
+		{"machine api.cloudflare.com password token123\n", secret{Token: "token123"}, false},
+		{"machine api.cloudflare.com\n  login user@example.com\n  password key123\n", secret{Key: "key123", Email: "user@example.com"}, false},
+		{"machine example.com login other password other\nmachine api.cloudflare.com password token123\n", secret{Token: "token123"}, false},
+		{"machine api.cloudflare.com password token123\nmachine example.com password other\n", secret{Token: "token123"}, false},
+		{"macdef init\ncd /tmp\nmachine api.cloudflare.com password macro\n\nmachine api.cloudflare.com password token123\n", secret{Token: "token123"}, false},
+		{"machine example.com password other\ndefault password token123\n", secret{Token: "token123"}, false},
+		{"machine example.com password other\n", secret{}, true},
+		{"", secret{}, true},
+	}
+
+	for i, in := range cases {
+		s, err := parseNetrc(strings.NewReader(in.in), cloudflareHost)
+		if in.err && err == nil {
+			t.Errorf("%d: parseNetrc() failed to err", i)
+		}
+
+		if !in.err && err != nil {
+			t.Errorf("%d: parseNetrc() returned error: %s", i, err.Error())
+		}
+
+		if s != in.expected {
+			t.Errorf("%d: parseNetrc() returned %+v, expected %+v", i, s, in.expected)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// flattenAnnotation marks a CNAME at the zone apex as intentionally
+	// flattened.
+	flattenAnnotation = "cfzone:flatten"
+
+	// The CNAME flattening modes of a zone. flattenRoot only flattens a
+	// CNAME at the zone apex, flattenAll flattens every CNAME.
+	flattenRoot = "root"
+	flattenAll  = "all"
+)
+
+type (
+	// flatteningMode is the CNAME flattening mode of a zone as set by
+	// -cname-flattening. An empty mode leaves the setting untouched.
+	flatteningMode string
+
+	// flatteningProvider is a Provider able to change the CNAME flattening
+	// mode of the zone.
+	flatteningProvider interface {
+		Provider
+
+		// CNAMEFlattening will return the CNAME flattening mode.
+		CNAMEFlattening() (string, error)
+
+		// SetCNAMEFlattening will change the CNAME flattening mode.
+		SetCNAMEFlattening(mode string) error
+	}
+)
+
+// String implements flag.Value.
+func (m *flatteningMode) String() string {
+	return string(*m)
+}
+
+// Set implements flag.Value.
+func (m *flatteningMode) Set(value string) error {
+	switch value {
+	case "", flattenRoot, flattenAll:
+		*m = flatteningMode(value)
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown mode '%s', must be %s or %s", value, flattenRoot, flattenAll)
+}
+
+// apexCNAME will return the CNAME record at the apex of z, or nil if there
+// is none.
+func (z *parsedZone) apexCNAME() *Record {
+	for i, r := range z.Records {
+		if r.Type == "CNAME" && strings.EqualFold(r.Name, z.Name) {
+			return &z.Records[i]
+		}
+	}
+
+	return nil
+}
+
+// lintApexCNAME will find a CNAME at the zone apex. Only Cloudflare
+// flattens it. Without the annotation a note is given, in case it's there
+// by mistake.
+func lintApexCNAME(zone *parsedZone) []lintFinding {
+	r := zone.apexCNAME()
+
+	switch {
+	case r == nil:
+		return []lintFinding{}
+
+	case providerName != cloudflareProviderName:
+		return []lintFinding{{
+			Record:  *r,
+			Message: "CNAME at the zone apex is only supported by Cloudflare",
+		}}
+
+	case !strings.Contains(r.Comment, flattenAnnotation):
+		return []lintFinding{{
+			Record:  *r,
+			Message: fmt.Sprintf("CNAME at the zone apex is flattened by Cloudflare, annotate it with '%s' if intentional", flattenAnnotation),
+			Note:    true,
+		}}
+	}
+
+	return []lintFinding{}
+}
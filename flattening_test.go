@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testFlatteningZone = `$ORIGIN example.com.
+@ 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 3600 600 86400 300
+@ 3600 IN NS ns1.example.com.
+@ 300 IN CNAME example.pages.dev. ; cfzone:flatten
+@ 300 IN MX 10 mail.example.net.
+@ 300 IN TXT "v=spf1 -all"
+`
+
+func TestFlatteningModeSet(t *testing.T) {
+	cases := map[string]bool{
+		"":                true,
+		flattenRoot:       true,
+		flattenAll:        true,
+		"flatten_all":     false,
+		"ALL":             false,
+		"flatten_at_root": false,
+	}
+
+	for in, valid := range cases {
+		var m flatteningMode
+
+		err := m.Set(in)
+		if valid && (err != nil || m.String() != in) {
+			t.Errorf("Set(%s) failed: %v", in, err)
+		}
+
+		if !valid && err == nil {
+			t.Errorf("Set(%s) failed to err", in)
+		}
+	}
+}
+
+func TestApexCNAME(t *testing.T) {
+	z, err := parseZoneFile(strings.NewReader(testFlatteningZone), "", cfAutoTTL, cfCacheTTL)
+	if err != nil {
+		t.Fatalf("parseZoneFile() returned error: %s", err.Error())
+	}
+
+	r := z.apexCNAME()
+	if r == nil || r.Content != "example.pages.dev" || r.Line != 4 {
+		t.Errorf("apexCNAME() returned %+v", r)
+	}
+
+	z.Records = z.Records[1:]
+	if z.apexCNAME() != nil {
+		t.Errorf("apexCNAME() found CNAME in zone without one")
+	}
+}
+
+func TestLintApexCNAME(t *testing.T) {
+	defer func() { providerName = cloudflareProviderName }()
+
+	lint := func(zone string) []string {
+		z, err := parseZoneFile(strings.NewReader(zone), "", cfAutoTTL, cfCacheTTL)
+		if err != nil {
+			t.Fatalf("parseZoneFile() returned error: %s", err.Error())
+		}
+
+		result := []string{}
+		for _, f := range lintZone(&zoneFile{Paths: []string{"example.com.zone"}, Zone: z}) {
+			result = append(result, f.String())
+		}
+
+		return result
+	}
+
+	// The SOA, NS, MX and TXT records at the apex don't conflict.
+	findings := lint(testFlatteningZone)
+	if len(findings) != 0 {
+		t.Errorf("lintZone() returned findings for annotated apex CNAME: %q", findings)
+	}
+
+	findings = lint(strings.Replace(testFlatteningZone, " ; cfzone:flatten", "", 1) + "@ 300 IN A 127.0.0.1\n")
+	expected := []string{
+		"example.com.zone:4: example.com CNAME: CNAME can't share its name with the A record at line 7",
+		"example.com.zone:4: example.com CNAME: note: CNAME at the zone apex is flattened by Cloudflare, annotate it with 'cfzone:flatten' if intentional",
+	}
+
+	if strings.Join(findings, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("lintZone() returned wrong findings, got:\n%s\nexpected:\n%s", strings.Join(findings, "\n"), strings.Join(expected, "\n"))
+	}
+
+	providerName = rfc2136ProviderName
+
+	findings = lint(testFlatteningZone)
+	if len(findings) != 3 || !strings.HasSuffix(findings[2], "CNAME at the zone apex is only supported by Cloudflare") {
+		t.Errorf("lintZone() returned wrong findings for other provider: %q", findings)
+	}
+}
+
+func TestPlanApexCNAME(t *testing.T) {
+	z, err := parseZoneFile(strings.NewReader(testFlatteningZone), "", cfAutoTTL, cfCacheTTL)
+	if err != nil {
+		t.Fatalf("parseZoneFile() returned error: %s", err.Error())
+	}
+
+	zf := &zoneFile{
+		Paths:   []string{"example.com.zone"},
+		Zone:    z,
+		Options: options{MaxDeletes: -1, MaxChangePercent: -1},
+	}
+
+	// The fake provider doesn't allow a CNAME at the zone apex.
+	_, err = planZone(fakeConnector(&fakeProvider{}), zf)
+	if err == nil || !strings.Contains(err.Error(), "CNAME at the zone apex") {
+		t.Errorf("planZone() returned wrong error: %v", err)
+	}
+
+	p, err := planZone(fakeConnector(&cloudflareProvider{api: &fakeCloudflareAPI{}, zoneID: "z1"}), zf)
+	if err != nil {
+		t.Fatalf("planZone() returned error: %s", err.Error())
+	}
+
+	if len(p.Adds) != 3 {
+		t.Errorf("planZone() returned wrong adds:\n%s", zoneString(p.Adds))
+	}
+
+	p.Release()
+}
+
+func TestPlanCNAMEFlattening(t *testing.T) {
+	z, err := parseZoneFile(strings.NewReader(testFlatteningZone), "", cfAutoTTL, cfCacheTTL)
+	if err != nil {
+		t.Fatalf("parseZoneFile() returned error: %s", err.Error())
+	}
+
+	zf := &zoneFile{
+		Paths:   []string{"example.com.zone"},
+		Zone:    &parsedZone{Name: z.Name},
+		Options: options{MaxDeletes: -1, MaxChangePercent: -1, CNAMEFlattening: flattenAll},
+	}
+
+	_, err = planZone(fakeConnector(&fakeProvider{}), zf)
+	if err == nil || !strings.Contains(err.Error(), "CNAME flattening is not supported") {
+		t.Errorf("planZone() returned wrong error: %v", err)
+	}
+
+	api := &fakeCloudflareAPI{
+		settings: map[string]interface{}{cfFlatteningSetting: "flatten_at_root"},
+	}
+	connect := fakeConnector(&cloudflareProvider{api: api, zoneID: "z1"})
+
+	p, err := planZone(connect, zf)
+	if err != nil {
+		t.Fatalf("planZone() returned error: %s", err.Error())
+	}
+
+	if p.Flattening != flattenAll || p.Changes() != 1 {
+		t.Errorf("planZone() did not change CNAME flattening, got '%s' and %d change(s)", p.Flattening, p.Changes())
+	}
+
+	var b bytes.Buffer
+	p.Fprint(&b)
+
+	if !strings.Contains(b.String(), "cname-flattening: root -> all\n") {
+		t.Errorf("Fprint() did not show the CNAME flattening change, got:\n%s", b.String())
+	}
+
+	err = applyPlans([]*plan{p}, 1)[0]
+	if err != nil {
+		t.Fatalf("applyPlans() returned error: %s", err.Error())
+	}
+
+	if api.settings[cfFlatteningSetting] != "flatten_all" {
+		t.Errorf("applyPlans() set CNAME flattening to '%v'", api.settings[cfFlatteningSetting])
+	}
+
+	p, err = planZone(connect, zf)
+	if err != nil {
+		t.Fatalf("planZone() returned error: %s", err.Error())
+	}
+
+	if p.Flattening != "" || p.Changes() != 0 {
+		t.Errorf("planZone() changed CNAME flattening again")
+	}
+
+	p.Release()
+}
+
+func TestLintCommandApexCNAMENote(t *testing.T) {
+	defer func() { stdout = os.Stdout }()
+	out := &bytes.Buffer{}
+	stdout = out
+
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "example.com.zone")
+	ioutil.WriteFile(path, []byte(strings.Replace(testFlatteningZone, " ; cfzone:flatten", "", 1)), 0600)
+
+	func() {
+		defer expectExit(t, -1)
+
+		lintCommand([]string{"lint", path})
+	}()
+
+	if !strings.Contains(out.String(), "note: CNAME at the zone apex") || strings.Contains(out.String(), "problem(s) found") {
+		t.Errorf("lintCommand() did not report the apex CNAME as a note, got:\n%s", out.String())
+	}
+}
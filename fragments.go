@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// setSources will record path as the source of all records in z.
+func (z *parsedZone) setSources(path string) {
+	for i := range z.Records {
+		z.Records[i].Source = path
+	}
+
+	for i := range z.Protected {
+		z.Protected[i].Source = path
+	}
+}
+
+// sources will map each name and type in c to the file defining it.
+func (c recordCollection) sources() map[ownerKey]string {
+	result := map[ownerKey]string{}
+
+	for _, r := range c {
+		key := ownerKey{Name: r.Name, Type: r.Type}
+		if _, found := result[key]; !found {
+			result[key] = r.Source
+		}
+	}
+
+	return result
+}
+
+// Merge will add the records from the zone fragment other to z. Each name
+// and type must be defined by a single fragment only.
+func (z *parsedZone) Merge(other *parsedZone) error {
+	sources := z.Records.sources()
+	otherSources := other.Records.sources()
+
+	for _, key := range other.Records.ownerKeys() {
+		if source, found := sources[key]; found {
+			return fmt.Errorf("%s %s in '%s' is defined in both '%s' and '%s'", key.Name, key.Type, z.Name, source, otherSources[key])
+		}
+	}
+
+	z.Records = append(z.Records, other.Records...)
+	z.Protected = append(z.Protected, other.Protected...)
+
+	return nil
+}
+
+// mergeZoneFiles will merge zone files for the same zone into a single
+// zone file. The order of the zones is kept.
+func mergeZoneFiles(files []*zoneFile) ([]*zoneFile, error) {
+	result := []*zoneFile{}
+	byName := map[string]*zoneFile{}
+
+	for _, zf := range files {
+		merged, found := byName[zf.Zone.Name]
+		if !found {
+			byName[zf.Zone.Name] = zf
+			result = append(result, zf)
+
+			continue
+		}
+
+		err := merged.Zone.Merge(zf.Zone)
+		if err != nil {
+			return nil, err
+		}
+
+		merged.Paths = append(merged.Paths, zf.Paths...)
+		merged.Rules = append(merged.Rules, zf.Rules...)
+
+		// The checksum of a merged zone covers all the fragments.
+		hasher := sha256.New()
+		hasher.Write(merged.Checksum)
+		hasher.Write(zf.Checksum)
+		merged.Checksum = hasher.Sum(nil)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func testFragment(path string, records ...Record) *zoneFile {
+	zone := &parsedZone{
+		Name:    "example.com",
+		Records: records,
+	}
+	zone.setSources(path)
+
+	return &zoneFile{
+		Paths:    []string{path},
+		Zone:     zone,
+		Checksum: []byte(path),
+	}
+}
+
+func TestMergeZoneFiles(t *testing.T) {
+	mail := testFragment("mail.zone",
+		Record{Name: "example.com", Type: "MX", Content: "mx1.example.com"},
+		Record{Name: "example.com", Type: "MX", Content: "mx2.example.com"},
+	)
+	web := testFragment("web.zone",
+		Record{Name: "www.example.com", Type: "A", Content: "127.0.0.1"},
+		Record{Name: "example.com", Type: "A", Content: "127.0.0.1"},
+	)
+	other := &zoneFile{
+		Paths: []string{"example.net"},
+		Zone:  &parsedZone{Name: "example.net", Records: recordCollection{}},
+	}
+
+	files, err := mergeZoneFiles([]*zoneFile{mail, other, web})
+	if err != nil {
+		t.Fatalf("mergeZoneFiles() returned error: %s", err.Error())
+	}
+
+	if len(files) != 2 || files[0].Zone.Name != "example.com" || files[1].Zone.Name != "example.net" {
+		t.Fatalf("mergeZoneFiles() returned wrong zones: %+v", files)
+	}
+
+	merged := files[0]
+	if len(merged.Zone.Records) != 4 || len(merged.Paths) != 2 {
+		t.Errorf("mergeZoneFiles() did not merge fragments: %+v", merged)
+	}
+
+	sources := merged.Zone.Records.sources()
+	if sources[ownerKey{Name: "www.example.com", Type: "A"}] != "web.zone" {
+		t.Errorf("mergeZoneFiles() lost the source of www.example.com")
+	}
+
+	if sources[ownerKey{Name: "example.com", Type: "MX"}] != "mail.zone" {
+		t.Errorf("mergeZoneFiles() lost the source of example.com MX")
+	}
+
+	conflict := testFragment("infra.zone",
+		Record{Name: "www.example.com", Type: "A", Content: "127.0.0.2"},
+	)
+
+	_, err = mergeZoneFiles([]*zoneFile{testFragment("web.zone", web.Zone.Records...), conflict})
+	if err == nil || !strings.Contains(err.Error(), "'web.zone' and 'infra.zone'") {
+		t.Errorf("mergeZoneFiles() failed to detect conflict, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ignoreFileName is the name of the ignore file looked up next to the zone
+// file if no ignore file is specified.
+const ignoreFileName = ".cfzoneignore"
+
+type (
+	// pattern matches a string by either a glob or a regular expression.
+	pattern struct {
+		glob string
+		re   *regexp.Regexp
+	}
+
+	// ignoreRule describes records to be ignored. All conditions present
+	// must match for a record to be ignored.
+	ignoreRule struct {
+		Type    string
+		Name    *pattern
+		Content *pattern
+	}
+
+	// ignoreRules is a list of rules. A record matching any of the rules
+	// will be ignored.
+	ignoreRules []ignoreRule
+)
+
+// newPattern will parse a single pattern. If op is "~" value is treated as a
+// regular expression, otherwise as a glob.
+func newPattern(op string, value string) (*pattern, error) {
+	if op == "~" {
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return &pattern{re: re}, nil
+	}
+
+	// Check the glob for syntax errors before using it.
+	_, err := path.Match(value, "")
+	if err != nil {
+		return nil, fmt.Errorf("bad glob '%s': %s", value, err.Error())
+	}
+
+	return &pattern{glob: value}, nil
+}
+
+// Match will return true if s matches the pattern.
+func (p *pattern) Match(s string) bool {
+	if p.re != nil {
+		return p.re.MatchString(s)
+	}
+
+	match, _ := path.Match(p.glob, s)
+
+	return match
+}
+
+// parseIgnoreRule will parse a single line from an ignore file. The line
+// consists of one or more whitespace separated conditions:
+//
+//	type=TXT                     Record type
+//	name=<glob> or name~<regex>  Record name
+//	content=<glob> or content~<regex>
+//	<glob>                       Shorthand for name=<glob>
+func parseIgnoreRule(line string) (ignoreRule, error) {
+	var rule ignoreRule
+
+	for _, field := range strings.Fields(line) {
+		i := strings.IndexAny(field, "=~")
+		if i < 0 {
+			field = "name=" + field
+			i = 4
+		}
+
+		key, op, value := field[:i], field[i:i+1], field[i+1:]
+		if value == "" {
+			return rule, fmt.Errorf("empty value for '%s'", key)
+		}
+
+		var err error
+
+		switch key {
+		case "type":
+			if op != "=" {
+				return rule, fmt.Errorf("type must be matched using '='")
+			}
+			rule.Type = strings.ToUpper(value)
+
+		case "name":
+			rule.Name, err = newPattern(op, value)
+
+		case "content":
+			rule.Content, err = newPattern(op, value)
+
+		default:
+			return rule, fmt.Errorf("unknown condition '%s'", key)
+		}
+
+		if err != nil {
+			return rule, err
+		}
+	}
+
+	return rule, nil
+}
+
+// parseIgnoreRules will parse ignore rules from r. Empty lines and lines
+// starting with '#' are skipped.
+func parseIgnoreRules(r io.Reader) (ignoreRules, error) {
+	rules := ignoreRules{}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule, err := parseIgnoreRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err.Error())
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// readIgnoreFile will read ignore rules from the file at path. If mustExist
+// is false, a missing file results in an empty set of rules.
+func readIgnoreFile(path string, mustExist bool) (ignoreRules, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) && !mustExist {
+		return ignoreRules{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseIgnoreRules(f)
+}
+
+// String implements flag.Value.
+func (rules *ignoreRules) String() string {
+	return ""
+}
+
+// Set implements flag.Value. Every call will add a rule to rules.
+func (rules *ignoreRules) Set(value string) error {
+	rule, err := parseIgnoreRule(value)
+	if err != nil {
+		return err
+	}
+
+	*rules = append(*rules, rule)
+
+	return nil
+}
+
+// Match will return true if r matches the rule.
+func (rule ignoreRule) Match(r Record) bool {
+	if rule.Type != "" && rule.Type != r.Type {
+		return false
+	}
+
+	if rule.Name != nil && !rule.Name.Match(r.Name) {
+		return false
+	}
+
+	if rule.Content != nil && !rule.Content.Match(r.Content) {
+		return false
+	}
+
+	return true
+}
+
+// Match will return true if r matches any of the rules.
+func (rules ignoreRules) Match(r Record) bool {
+	for _, rule := range rules {
+		if rule.Match(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Ignore will return all records from c not matched by rules.
+func (c recordCollection) Ignore(rules ignoreRules) recordCollection {
+	result := recordCollection{}
+
+	for _, r := range c {
+		if !rules.Match(r) {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIgnoreRules(t *testing.T) {
+	cases := []struct {
+		in    string
+		count int
+		err   bool
+	}{
+		{"", 0, false},
+		{"# comment\n\n", 0, false},
+		{"_acme-challenge.*", 1, false},
+		{"_acme-challenge.*\ntype=TXT content=google-site-verification*\n", 2, false},
+		{"name~^_dmarc\\.", 1, false},
+		{"name~(", 0, true},
+		{"name=[", 0, true},
+		{"type~TXT", 0, true},
+		{"type=", 0, true},
+		{"ttl=300", 0, true},
+	}
+
+	for i, in := range cases {
+		rules, err := parseIgnoreRules(strings.NewReader(in.in))
+		if in.err && err == nil {
+			t.Errorf("%d: parseIgnoreRules() failed to err on [%s]", i, in.in)
+		}
+
+		if !in.err && err != nil {
+			t.Errorf("%d: parseIgnoreRules() returned error on [%s]: %s", i, in.in, err.Error())
+		}
+
+		if len(rules) != in.count {
+			t.Errorf("%d: parseIgnoreRules() returned %d rules for [%s], expected %d", i, len(rules), in.in, in.count)
+		}
+	}
+}
+
+func TestIgnoreRulesMatch(t *testing.T) {
+	rules, err := parseIgnoreRules(strings.NewReader(`
+_acme-challenge.*
+type=TXT content=google-site-verification*
+name~^_dmarc\.
+type=srv
+`))
+	if err != nil {
+		t.Fatalf("parseIgnoreRules() returned error: %s", err.Error())
+	}
+
+	cases := []struct {
+		in       Record
+		expected bool
+	}{
+		{Record{Type: "TXT", Name: "_acme-challenge.www.example.com", Content: "token"}, true},
+		{Record{Type: "TXT", Name: "www.example.com", Content: "token"}, false},
+		{Record{Type: "TXT", Name: "example.com", Content: "google-site-verification=abc"}, true},
+		{Record{Type: "A", Name: "example.com", Content: "google-site-verification=abc"}, false},
+		{Record{Type: "TXT", Name: "_dmarc.example.com", Content: "v=DMARC1"}, true},
+		{Record{Type: "TXT", Name: "x_dmarc.example.com", Content: "v=DMARC1"}, false},
+		{Record{Type: "SRV", Name: "_sip._tcp.example.com"}, true},
+	}
+
+	for i, in := range cases {
+		result := rules.Match(in.in)
+		if result != in.expected {
+			t.Errorf("%d: Match() returned wrong result for %+v, got %v, expected %v", i, in.in, result, in.expected)
+		}
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	a1 := Record{Type: "A", Name: "a1", Content: "127.0.0.1"}
+	a2 := Record{Type: "A", Name: "a2", Content: "127.0.0.2"}
+	txt := Record{Type: "TXT", Name: "a1", Content: "hello"}
+
+	result := recordCollection{a1, a2, txt}.Ignore(ignoreRules{{Type: "TXT"}})
+	if !reflect.DeepEqual(result, recordCollection{a1, a2}) {
+		t.Errorf("Ignore() returned wrong result: %+v", result)
+	}
+
+	result = recordCollection{a1, a2, txt}.Ignore(ignoreRules{})
+	if !reflect.DeepEqual(result, recordCollection{a1, a2, txt}) {
+		t.Errorf("Ignore() with no rules returned wrong result: %+v", result)
+	}
+}
+
+func TestReadIgnoreFile(t *testing.T) {
+	rules, err := readIgnoreFile("/non/existing/.cfzoneignore", false)
+	if err != nil || len(rules) != 0 {
+		t.Errorf("readIgnoreFile() did not accept missing optional file")
+	}
+
+	_, err = readIgnoreFile("/non/existing/.cfzoneignore", true)
+	if err == nil {
+		t.Errorf("readIgnoreFile() did not err on missing file")
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// cfMinTTL and cfMaxTTL are the limits of TTLs at Cloudflare.
+	cfMinTTL = 60
+	cfMaxTTL = 86400
+)
+
+type (
+	// lintFinding is a problem found in a zone file by "cfzone lint". A
+	// note is only informational and doesn't fail the lint.
+	lintFinding struct {
+		Path    string
+		Record  Record
+		Message string
+		Note    bool
+	}
+
+	// lintCheck will return the problems found in a zone.
+	lintCheck func(zone *parsedZone) []lintFinding
+)
+
+// lintChecks are the checks run by "cfzone lint". Checks of Cloudflare
+// limitations are only run for the Cloudflare provider.
+var lintChecks = []struct {
+	cloudflare bool
+	check      lintCheck
+}{
+	{false, lintCNAMEConflicts},
+	{true, lintProxiable},
+	{true, lintTTLRange},
+	{false, lintDuplicates},
+	{false, lintMXTargets},
+	{false, lintWildcardShadows},
+	{false, lintApexCNAME},
+}
+
+// String implements fmt.Stringer.
+func (f lintFinding) String() string {
+	location := f.Path
+	if f.Record.Line > 0 {
+		location = fmt.Sprintf("%s:%d", f.Path, f.Record.Line)
+	}
+
+	message := f.Message
+	if f.Note {
+		message = "note: " + message
+	}
+
+	return fmt.Sprintf("%s: %s %s: %s", location, f.Record.Name, f.Record.Type, message)
+}
+
+// lineOf will return " at line n" for r, or an empty string if the line is
+// unknown.
+func lineOf(r Record) string {
+	if r.Line == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(" at line %d", r.Line)
+}
+
+// lintCNAMEConflicts will find CNAME records sharing their name with other
+// records. The SOA and NS records at the zone apex are not synced and never
+// conflict.
+func lintCNAMEConflicts(zone *parsedZone) []lintFinding {
+	byName := map[string]recordCollection{}
+	for _, r := range zone.Records {
+		name := strings.ToLower(r.Name)
+		byName[name] = append(byName[name], r)
+	}
+
+	findings := []lintFinding{}
+	for _, r := range zone.Records {
+		if r.Type != "CNAME" {
+			continue
+		}
+
+		apex := strings.EqualFold(r.Name, zone.Name)
+		for _, other := range byName[strings.ToLower(r.Name)] {
+			if other.Type == "CNAME" && FullMatch(other, r) {
+				continue
+			}
+
+			// Cloudflare flattens a CNAME at the zone apex, it can share
+			// its name with other records than addresses.
+			if apex && providerName == cloudflareProviderName && other.Type != "A" && other.Type != "AAAA" && other.Type != "CNAME" {
+				continue
+			}
+
+			findings = append(findings, lintFinding{
+				Record:  r,
+				Message: fmt.Sprintf("CNAME can't share its name with the %s record%s", other.Type, lineOf(other)),
+			})
+		}
+	}
+
+	return findings
+}
+
+// lintProxiable will find proxied records of types not proxied by
+// Cloudflare.
+func lintProxiable(zone *parsedZone) []lintFinding {
+	findings := []lintFinding{}
+
+	for _, r := range zone.Records {
+		if !r.Proxied {
+			continue
+		}
+
+		switch r.Type {
+		case "A", "AAAA", "CNAME":
+		default:
+			findings = append(findings, lintFinding{
+				Record:  r,
+				Message: fmt.Sprintf("%s records can't be proxied", r.Type),
+			})
+		}
+	}
+
+	return findings
+}
+
+// lintTTLRange will find TTLs not accepted by Cloudflare.
+func lintTTLRange(zone *parsedZone) []lintFinding {
+	findings := []lintFinding{}
+
+	for _, r := range zone.Records {
+		if r.Proxied || r.TTL == cfAutoTTL {
+			continue
+		}
+
+		if r.TTL < cfMinTTL || r.TTL > cfMaxTTL {
+			findings = append(findings, lintFinding{
+				Record:  r,
+				Message: fmt.Sprintf("TTL %d is outside the range of %d to %d seconds", r.TTL, cfMinTTL, cfMaxTTL),
+			})
+		}
+	}
+
+	return findings
+}
+
+// lintDuplicates will find records with the same content as an earlier
+// record. The TTL doesn't matter.
+func lintDuplicates(zone *parsedZone) []lintFinding {
+	findings := []lintFinding{}
+	seen := map[string]Record{}
+
+	for _, r := range zone.Records {
+		key := fmt.Sprintf("%s %s %d %s", strings.ToLower(r.Name), r.Type, r.Priority, r.Content)
+
+		first, found := seen[key]
+		if !found {
+			seen[key] = r
+
+			continue
+		}
+
+		findings = append(findings, lintFinding{
+			Record:  r,
+			Message: "duplicate of the record" + lineOf(first),
+		})
+	}
+
+	return findings
+}
+
+// lintMXTargets will find MX records pointing at a CNAME, which is not
+// allowed by RFC 2181.
+func lintMXTargets(zone *parsedZone) []lintFinding {
+	cnames := map[string]Record{}
+	for _, r := range zone.Records {
+		if r.Type == "CNAME" {
+			cnames[strings.ToLower(r.Name)] = r
+		}
+	}
+
+	findings := []lintFinding{}
+	for _, r := range zone.Records {
+		if r.Type != "MX" {
+			continue
+		}
+
+		if cname, found := cnames[strings.ToLower(strings.Trim(r.Content, "."))]; found {
+			findings = append(findings, lintFinding{
+				Record:  r,
+				Message: fmt.Sprintf("MX target %s is a CNAME%s", cname.Name, lineOf(cname)),
+			})
+		}
+	}
+
+	return findings
+}
+
+// lintWildcardShadows will find names below a wildcard which don't have
+// records of the type of the wildcard. The wildcard doesn't answer for a
+// name with records of its own, not even for other types. Names are only
+// checked against the closest wildcard above them, and names with a CNAME
+// are skipped, the CNAME answers for every type. This is often intended,
+// the findings are notes.
+func lintWildcardShadows(zone *parsedZone) []lintFinding {
+	types := map[string]map[string]bool{}
+	for _, r := range zone.Records {
+		name := strings.ToLower(r.Name)
+		if types[name] == nil {
+			types[name] = map[string]bool{}
+		}
+
+		types[name][r.Type] = true
+	}
+
+	wildcards := zone.Records.Wildcards()
+
+	// closest will return the parent of the closest wildcard above name.
+	closest := func(name string) string {
+		result := ""
+		for _, w := range wildcards {
+			parent := strings.ToLower(wildcardParent(w.Name))
+			if strings.HasSuffix(name, "."+parent) && len(parent) > len(result) {
+				result = parent
+			}
+		}
+
+		return result
+	}
+
+	findings := []lintFinding{}
+	reported := map[string]bool{}
+	for _, w := range wildcards {
+		parent := strings.ToLower(wildcardParent(w.Name))
+
+		for _, r := range zone.Records {
+			name := strings.ToLower(r.Name)
+			key := fmt.Sprintf("%s %s %s", strings.ToLower(w.Name), w.Type, name)
+			if isWildcard(name) || closest(name) != parent || types[name][w.Type] || types[name]["CNAME"] || reported[key] {
+				continue
+			}
+
+			// Every name is reported once for each wildcard and type.
+			reported[key] = true
+
+			findings = append(findings, lintFinding{
+				Record:  w,
+				Message: fmt.Sprintf("wildcard doesn't answer %s queries for %s, which has records of its own%s", w.Type, r.Name, lineOf(r)),
+				Note:    true,
+			})
+		}
+	}
+
+	return findings
+}
+
+// lintZone will run the checks on zf. The findings are ordered by file and
+// line.
+func lintZone(zf *zoneFile) []lintFinding {
+	findings := []lintFinding{}
+
+	for _, c := range lintChecks {
+		if c.cloudflare && providerName != cloudflareProviderName {
+			continue
+		}
+
+		for _, f := range c.check(zf.Zone) {
+			f.Path = f.Record.Source
+			if f.Path == "" {
+				f.Path = zf.Paths[0]
+			}
+
+			findings = append(findings, f)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+
+		return findings[i].Record.Line < findings[j].Record.Line
+	})
+
+	return findings
+}
+
+// lintCommand implements "cfzone lint". It will check zone files for
+// problems without contacting the provider, and exit non-zero if any are
+// found.
+func lintCommand(args []string) {
+	paths, err := parseArguments(args)
+	if err != nil {
+		exit(1)
+	}
+
+	paths, err = expandPaths(paths)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err.Error())
+		exit(1)
+	}
+
+	if len(paths) == 0 {
+		fmt.Fprintf(stderr, "No zone files found\n")
+		exit(1)
+	}
+
+	files := make([]*zoneFile, 0, len(paths))
+	failed := false
+	for _, path := range paths {
+		zf, err := loadZone(path)
+		if err != nil {
+			// All files are checked, even if one is broken.
+			fmt.Fprintf(stdout, "%s\n", err.Error())
+			failed = true
+
+			continue
+		}
+
+		files = append(files, zf)
+	}
+
+	files, err = mergeZoneFiles(files)
+	if err != nil {
+		fmt.Fprintf(stdout, "%s\n", err.Error())
+		exit(1)
+	}
+
+	findings := []lintFinding{}
+	for _, zf := range files {
+		findings = append(findings, lintZone(zf)...)
+	}
+
+	problems := 0
+	for _, f := range findings {
+		fmt.Fprintf(stdout, "%s\n", f)
+
+		if !f.Note {
+			problems++
+		}
+	}
+
+	if problems > 0 {
+		fmt.Fprintf(stdout, "%d problem(s) found\n", problems)
+	}
+
+	if failed || problems > 0 {
+		exit(1)
+	}
+}
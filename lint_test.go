@@ -0,0 +1,149 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testLintZone = `$ORIGIN example.com.
+@ 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 3600 600 86400 300
+@ 300 IN A 127.0.0.1
+www 300 IN CNAME example.com.
+www 300 IN TXT "site"
+txt 1 IN TXT "proxied"
+short 30 IN A 127.0.0.2
+long 604800 IN A 127.0.0.3
+auto 0 IN A 127.0.0.4
+proxied 1 IN A 127.0.0.5
+dup 300 IN A 127.0.0.6
+dup 600 IN A 127.0.0.6
+@ 300 IN MX 10 www.example.com.
+@ 300 IN MX 20 mail.example.com.
+mail 300 IN A 127.0.0.7
+`
+
+func TestLintZone(t *testing.T) {
+	z, err := parseZoneFile(strings.NewReader(testLintZone), "", cfAutoTTL, cfCacheTTL)
+	if err != nil {
+		t.Fatalf("parseZoneFile() returned error: %s", err.Error())
+	}
+
+	z.setSources("example.com.zone")
+
+	findings := lintZone(&zoneFile{Paths: []string{"example.com.zone"}, Zone: z})
+
+	result := []string{}
+	for _, f := range findings {
+		result = append(result, f.String())
+	}
+
+	expected := []string{
+		"example.com.zone:4: www.example.com CNAME: CNAME can't share its name with the TXT record at line 5",
+		"example.com.zone:6: txt.example.com TXT: TXT records can't be proxied",
+		"example.com.zone:7: short.example.com A: TTL 30 is outside the range of 60 to 86400 seconds",
+		"example.com.zone:8: long.example.com A: TTL 604800 is outside the range of 60 to 86400 seconds",
+		"example.com.zone:12: dup.example.com A: duplicate of the record at line 11",
+		"example.com.zone:13: example.com MX: MX target www.example.com is a CNAME at line 4",
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("lintZone() returned wrong findings, got:\n%s\nexpected:\n%s", strings.Join(result, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestLintWildcardShadows(t *testing.T) {
+	in := `$ORIGIN example.com.
+@ 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 3600 600 86400 300
+*.apps 300 IN A 127.0.0.1
+*.apps 300 IN A 127.0.0.2
+*.apps 300 IN MX 10 mail.example.com.
+web.apps 300 IN A 127.0.0.3
+web.apps 300 IN TXT "web"
+api.apps 300 IN TXT "api"
+www.apps 300 IN CNAME web.apps
+_dmarc.apps 300 IN TXT "v=DMARC1; p=none"
+*.example.com. 300 IN A 127.0.0.4
+`
+
+	z, err := parseZoneFile(strings.NewReader(in), "", cfAutoTTL, cfCacheTTL)
+	if err != nil {
+		t.Fatalf("parseZoneFile() returned error: %s", err.Error())
+	}
+
+	result := []string{}
+	for _, f := range lintWildcardShadows(z) {
+		f.Path = "example.com.zone"
+		result = append(result, f.String())
+	}
+
+	expected := []string{
+		"example.com.zone:3: *.apps.example.com A: note: wildcard doesn't answer A queries for api.apps.example.com, which has records of its own at line 8",
+		"example.com.zone:3: *.apps.example.com A: note: wildcard doesn't answer A queries for _dmarc.apps.example.com, which has records of its own at line 10",
+		"example.com.zone:5: *.apps.example.com MX: note: wildcard doesn't answer MX queries for web.apps.example.com, which has records of its own at line 6",
+		"example.com.zone:5: *.apps.example.com MX: note: wildcard doesn't answer MX queries for api.apps.example.com, which has records of its own at line 8",
+		"example.com.zone:5: *.apps.example.com MX: note: wildcard doesn't answer MX queries for _dmarc.apps.example.com, which has records of its own at line 10",
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("lintWildcardShadows() returned wrong findings, got:\n%s\nexpected:\n%s", strings.Join(result, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestLintZoneProvider(t *testing.T) {
+	defer func() { providerName = cloudflareProviderName }()
+	providerName = rfc2136ProviderName
+
+	z := &parsedZone{
+		Name: "example.com",
+		Records: recordCollection{
+			{Type: "A", Name: "short.example.com", Content: "127.0.0.1", TTL: 30},
+		},
+	}
+
+	findings := lintZone(&zoneFile{Paths: []string{"example.com.zone"}, Zone: z})
+	if len(findings) != 0 {
+		t.Errorf("lintZone() ran Cloudflare checks for another provider: %v", findings)
+	}
+}
+
+func TestLintCommand(t *testing.T) {
+	defer func() { stdout = os.Stdout }()
+	stdout = ioutil.Discard
+
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	good := filepath.Join(dir, "good")
+	bad := filepath.Join(dir, "bad")
+	os.Mkdir(good, 0700)
+	os.Mkdir(bad, 0700)
+
+	ioutil.WriteFile(filepath.Join(good, "example.com.zone"), []byte(testStructuredBIND), 0600)
+	ioutil.WriteFile(filepath.Join(bad, "example.com.zone"), []byte(testLintZone), 0600)
+	ioutil.WriteFile(filepath.Join(bad, "broken.zone"), []byte("broken"), 0600)
+
+	func() {
+		defer expectExit(t, -1)
+
+		lintCommand([]string{"lint", good})
+	}()
+
+	func() {
+		defer expectExit(t, 1)
+
+		lintCommand([]string{"lint", bad})
+	}()
+
+	func() {
+		defer expectExit(t, 1)
+
+		lintCommand([]string{"lint", filepath.Join(dir, "empty")})
+	}()
+}
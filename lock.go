@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// lockPrefix is prepended to the zone name to form the name of the TXT
+// record used for locking a zone.
+const lockPrefix = "cfzone-lock."
+
+// lockPollInterval is the time to wait between attempts to acquire a lock.
+const lockPollInterval = 5 * time.Second
+
+var (
+	// These can be overridden for testing.
+	now   = time.Now
+	sleep = time.Sleep
+)
+
+type (
+	// lockInfo is the content of a lock record.
+	lockInfo struct {
+		Owner    string
+		Acquired time.Time
+		Expires  time.Time
+	}
+
+	// zoneLock is a lock held on a zone.
+	zoneLock struct {
+		provider Provider
+		record   Record
+	}
+
+	// lockedError is returned when a zone is locked by someone else.
+	lockedError struct {
+		info lockInfo
+	}
+)
+
+// Error implements error.
+func (e *lockedError) Error() string {
+	return fmt.Sprintf("zone is locked by '%s' since %s (expires %s)",
+		e.info.Owner,
+		e.info.Acquired.Format(time.RFC3339),
+		e.info.Expires.Format(time.RFC3339))
+}
+
+// lockOwner will return a string identifying this cfzone run.
+func lockOwner() string {
+	return fmt.Sprintf("%s/%d", runUser(), os.Getpid())
+}
+
+// String will return the content of a lock record for l.
+func (l lockInfo) String() string {
+	return fmt.Sprintf("owner=%s,acquired=%s,expires=%s",
+		l.Owner,
+		l.Acquired.UTC().Format(time.RFC3339),
+		l.Expires.UTC().Format(time.RFC3339))
+}
+
+// parseLockInfo will parse the content of a lock record.
+func parseLockInfo(content string) (lockInfo, error) {
+	var l lockInfo
+	var err error
+
+	for _, field := range strings.Split(content, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return l, fmt.Errorf("malformed lock field '%s'", field)
+		}
+
+		switch kv[0] {
+		case "owner":
+			l.Owner = kv[1]
+		case "acquired":
+			l.Acquired, err = time.Parse(time.RFC3339, kv[1])
+		case "expires":
+			l.Expires, err = time.Parse(time.RFC3339, kv[1])
+		}
+
+		if err != nil {
+			return l, err
+		}
+	}
+
+	if l.Expires.IsZero() {
+		return l, errors.New("lock has no expiry")
+	}
+
+	return l, nil
+}
+
+// isLockRecord will return true if r is a lock record.
+func isLockRecord(r Record) bool {
+	return r.Type == "TXT" && strings.HasPrefix(r.Name, lockPrefix)
+}
+
+// acquireLock will try to acquire the lock for a zone. If the zone is
+// locked, acquireLock will retry until wait has passed. Locks past their
+// expiry are considered stale and will be removed.
+func acquireLock(provider Provider, zoneName string, owner string, ttl time.Duration, wait time.Duration) (*zoneLock, error) {
+	deadline := now().Add(wait)
+
+	for {
+		lock, err := tryLock(provider, zoneName, owner, ttl)
+		if err == nil {
+			return lock, nil
+		}
+
+		if _, locked := err.(*lockedError); !locked || !now().Before(deadline) {
+			return nil, err
+		}
+
+		sleep(lockPollInterval)
+	}
+}
+
+// tryLock will make a single attempt at acquiring the lock for a zone.
+func tryLock(provider Provider, zoneName string, owner string, ttl time.Duration) (*zoneLock, error) {
+	locks, err := liveLocks(provider, zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(locks) > 0 {
+		info, _ := parseLockInfo(locks[0].Content)
+
+		return nil, &lockedError{info: info}
+	}
+
+	acquired := now()
+	info := lockInfo{
+		Owner:    owner,
+		Acquired: acquired,
+		Expires:  acquired.Add(ttl),
+	}
+
+	record, err := provider.Create(Record{
+		Name:    lockPrefix + zoneName,
+		Content: info.String(),
+		Type:    "TXT",
+		TTL:     600,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &zoneLock{
+		provider: provider,
+		record:   record,
+	}
+
+	// Someone else could have created a lock at the same time. We check
+	// again and let the oldest lock win.
+	locks, err = liveLocks(provider, zoneName)
+	if err != nil {
+		lock.Release()
+
+		return nil, err
+	}
+
+	if len(locks) > 0 && locks[0].ID != lock.record.ID {
+		lock.Release()
+
+		info, _ := parseLockInfo(locks[0].Content)
+
+		return nil, &lockedError{info: info}
+	}
+
+	return lock, nil
+}
+
+// liveLocks will return all locks currently held on a zone, oldest first.
+// Stale locks are removed.
+func liveLocks(provider Provider, zoneName string) (recordCollection, error) {
+	records, err := provider.Records(Record{
+		Type: "TXT",
+		Name: lockPrefix + zoneName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	locks := recordCollection{}
+	acquired := map[string]time.Time{}
+
+	for _, r := range records {
+		info, err := parseLockInfo(r.Content)
+		if err == nil && now().Before(info.Expires) {
+			locks = append(locks, r)
+			acquired[r.ID] = info.Acquired
+
+			continue
+		}
+
+		fmt.Fprintf(stdout, "Breaking stale lock '%s'\n", r.Content)
+
+		err = provider.Delete(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(locks, func(i, j int) bool {
+		a, b := locks[i], locks[j]
+
+		if acquired[a.ID].Equal(acquired[b.ID]) {
+			return a.ID < b.ID
+		}
+
+		return acquired[a.ID].Before(acquired[b.ID])
+	})
+
+	return locks, nil
+}
+
+// Refresh will make sure the lock is still held and extend it by ttl. A
+// lock past its expiry can have been broken by another run at any time, and
+// is lost even if the record is still there.
+func (l *zoneLock) Refresh(ttl time.Duration) error {
+	records, err := l.provider.Records(Record{
+		Type: "TXT",
+		Name: l.record.Name,
+	})
+	if err != nil {
+		return err
+	}
+
+	held := false
+	for _, r := range records {
+		if r.ID == l.record.ID && r.Content == l.record.Content {
+			held = true
+		}
+	}
+
+	info, err := parseLockInfo(l.record.Content)
+	if err != nil || !held || !now().Before(info.Expires) {
+		return errors.New("the lock was lost, another run may have changed the zone")
+	}
+
+	info.Expires = now().Add(ttl)
+
+	record := l.record
+	record.Content = info.String()
+
+	err = l.provider.Update(record)
+	if err != nil {
+		return err
+	}
+
+	l.record = record
+
+	return nil
+}
+
+// Release will release the lock.
+func (l *zoneLock) Release() error {
+	return l.provider.Delete(l.record)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func fakeNow(t time.Time) func() {
+	now = func() time.Time { return t }
+	sleep = func(time.Duration) {}
+
+	return func() {
+		now = time.Now
+		sleep = time.Sleep
+	}
+}
+
+func TestLockInfo(t *testing.T) {
+	acquired := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	in := lockInfo{Owner: "agent@host/42", Acquired: acquired, Expires: acquired.Add(time.Minute)}
+
+	out, err := parseLockInfo(in.String())
+	if err != nil {
+		t.Fatalf("parseLockInfo() returned error: %s", err.Error())
+	}
+
+	if out != in {
+		t.Errorf("parseLockInfo() returned wrong info, got %+v, expected %+v", out, in)
+	}
+
+	for i, content := range []string{"", "owner=a", "owner=a,expires=never", "garbage"} {
+		_, err = parseLockInfo(content)
+		if err == nil {
+			t.Errorf("%d: parseLockInfo() failed to err on '%s'", i, content)
+		}
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	defer fakeNow(start)()
+
+	provider := &fakeProvider{}
+
+	lock, err := acquireLock(provider, "example.com", "first", time.Minute, 0)
+	if err != nil {
+		t.Fatalf("acquireLock() failed on unlocked zone: %s", err.Error())
+	}
+
+	if len(provider.records) != 1 || !isLockRecord(provider.records[0]) {
+		t.Fatalf("acquireLock() did not create a lock record: %+v", provider.records)
+	}
+
+	_, err = acquireLock(provider, "example.com", "second", time.Minute, 0)
+	if _, locked := err.(*lockedError); !locked {
+		t.Fatalf("acquireLock() did not fail on locked zone: %v", err)
+	}
+
+	err = lock.Release()
+	if err != nil || len(provider.records) != 0 {
+		t.Fatalf("Release() did not remove the lock record")
+	}
+}
+
+func TestAcquireLockStale(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	defer fakeNow(start)()
+
+	provider := &fakeProvider{}
+
+	_, err := acquireLock(provider, "example.com", "crashed", time.Minute, 0)
+	if err != nil {
+		t.Fatalf("acquireLock() failed on unlocked zone: %s", err.Error())
+	}
+
+	fakeNow(start.Add(2 * time.Minute))
+
+	lock, err := acquireLock(provider, "example.com", "second", time.Minute, 0)
+	if err != nil {
+		t.Fatalf("acquireLock() did not break stale lock: %s", err.Error())
+	}
+
+	if len(provider.records) != 1 || provider.records[0].ID != lock.record.ID {
+		t.Errorf("acquireLock() did not remove the stale lock: %+v", provider.records)
+	}
+}
+
+func TestAcquireLockWait(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	defer fakeNow(start)()
+
+	provider := &fakeProvider{}
+
+	first, err := acquireLock(provider, "example.com", "first", time.Hour, 0)
+	if err != nil {
+		t.Fatalf("acquireLock() failed on unlocked zone: %s", err.Error())
+	}
+
+	// Release the first lock while the second run is waiting.
+	sleeps := 0
+	sleep = func(time.Duration) {
+		sleeps++
+		first.Release()
+	}
+
+	_, err = acquireLock(provider, "example.com", "second", time.Hour, time.Minute)
+	if err != nil {
+		t.Fatalf("acquireLock() did not wait for lock: %s", err.Error())
+	}
+
+	if sleeps != 1 {
+		t.Errorf("acquireLock() slept %d times, expected 1", sleeps)
+	}
+}
+
+func TestAcquireLockRace(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	defer fakeNow(start)()
+
+	provider := &fakeProvider{}
+
+	// Simulate another run creating a lock at the same time as us.
+	provider.onCreate = func() {
+		provider.onCreate = nil
+		other := lockInfo{Owner: "other", Acquired: start.Add(-time.Second), Expires: start.Add(time.Hour)}
+		provider.records = append(provider.records, Record{ID: "other", Type: "TXT", Name: lockPrefix + "example.com", Content: other.String()})
+	}
+
+	_, err := acquireLock(provider, "example.com", "me", time.Hour, 0)
+	if _, locked := err.(*lockedError); !locked {
+		t.Fatalf("acquireLock() did not lose the race: %v", err)
+	}
+
+	if len(provider.records) != 1 || provider.records[0].ID != "other" {
+		t.Errorf("acquireLock() did not clean up after losing the race: %+v", provider.records)
+	}
+}
+
+func TestLockRefresh(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	defer fakeNow(start)()
+
+	provider := &fakeProvider{}
+
+	lock, err := acquireLock(provider, "example.com", "first", time.Minute, 0)
+	if err != nil {
+		t.Fatalf("acquireLock() failed on unlocked zone: %s", err.Error())
+	}
+
+	fakeNow(start.Add(30 * time.Second))
+
+	err = lock.Refresh(time.Minute)
+	if err != nil {
+		t.Fatalf("Refresh() failed on held lock: %s", err.Error())
+	}
+
+	info, _ := parseLockInfo(provider.records[0].Content)
+	if !info.Expires.Equal(start.Add(90*time.Second)) || provider.records[0].Content != lock.record.Content {
+		t.Errorf("Refresh() did not extend the lock, got '%s'", provider.records[0].Content)
+	}
+
+	// The lock expires and is taken over by another run.
+	fakeNow(start.Add(3 * time.Minute))
+
+	_, err = acquireLock(provider, "example.com", "second", time.Minute, 0)
+	if err != nil {
+		t.Fatalf("acquireLock() did not break stale lock: %s", err.Error())
+	}
+
+	err = lock.Refresh(time.Minute)
+	if err == nil {
+		t.Errorf("Refresh() failed to err on lost lock")
+	}
+}
+
+func TestLockRefreshExpired(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	defer fakeNow(start)()
+
+	provider := &fakeProvider{}
+
+	lock, err := acquireLock(provider, "example.com", "first", time.Minute, 0)
+	if err != nil {
+		t.Fatalf("acquireLock() failed on unlocked zone: %s", err.Error())
+	}
+
+	// Nobody broke the lock yet, but anybody could have.
+	fakeNow(start.Add(2 * time.Minute))
+
+	err = lock.Refresh(time.Minute)
+	if err == nil {
+		t.Errorf("Refresh() failed to err on expired lock")
+	}
+}
@@ -2,23 +2,22 @@ package main
 
 import (
 	"bufio"
-	"crypto/sha256"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
-	"strconv"
 	"strings"
+	"time"
 
-	"github.com/cloudflare/cloudflare-go"
+	"github.com/miekg/dns"
 )
 
 const (
 	// version must be updated when changes affecting cloudflare is made.
 	// This is to protect against undoing a fix or a feature applied to
 	// cfzone using an older version of cfzone.
-	version = 2019121201
+	version = 2026101805
 )
 
 var (
@@ -34,25 +33,89 @@ var (
 	leaveUnknown = false
 	ignoreSpf    = false
 	ignoreSrv    = false
+	ignoreFile   = ""
 	origin       = ""
 	zoneAutoTTL  = 0
 	zoneCacheTTL = 1
+
+	// owner enables ownership tracking when set. Only records marked as
+	// owned by owner will be deleted.
+	owner = ""
+
+	// Safety limits on destructive changes. A negative value disables the
+	// check. force can be set to sync regardless of the limits.
+	maxDeletes       = -1
+	maxChangePercent = -1.0
+	force            = false
+
+	// protectRules matches records which must never be deleted or changed
+	// unless allowProtected is set.
+	protectRules   = ignoreRules{}
+	allowProtected = false
+
+	// lockTTL is the time before a lock is considered stale. lockWait is the
+	// time to wait for a lock held by someone else.
+	lockTTL  = 10 * time.Minute
+	lockWait = time.Duration(0)
+
+	// commit is the git commit stored in the deployment metadata.
+	commit = ""
+
+	// configPath is the configuration file to read profiles from, and
+	// profileName the profile to use.
+	configPath  = defaultConfigFile
+	profileName = defaultProfile
+
+	// accountID is the Cloudflare account to look for the zone in. zoneID
+	// bypasses the lookup of the zone by name.
+	accountID = ""
+	zoneID    = ""
+
+	// cnameFlattening is the CNAME flattening mode to set for the zones.
+	cnameFlattening = flatteningMode("")
+
+	// Alternative sources for the credentials.
+	credentialFile = ""
+	credentialCmd  = ""
+	netrcPath      = ""
+
+	// parallel is the number of zones to apply changes to at a time.
+	parallel = 1
+
+	// providerName selects the provider hosting the zones. server and the
+	// TSIG settings are used by the RFC 2136 provider.
+	providerName  = cloudflareProviderName
+	server        = ""
+	tsigName      = ""
+	tsigAlgorithm = dns.HmacSHA256
+	tsigSecret    = ""
 )
 
 var (
+	apiToken = os.Getenv("CF_API_TOKEN")
 	apiKey   = os.Getenv("CF_API_KEY")
 	apiEmail = os.Getenv("CF_API_EMAIL")
 )
 
-// parseArguments tries to pass the arguments in args.
-// It will return the first ńon-flag argument, and any error encountered
-func parseArguments(args []string) (string, error) {
+// commands maps subcommands to their implementation. A command is given the
+// arguments starting with the name of the command.
+var commands = map[string]func(args []string){
+	"status":  statusCommand,
+	"serve":   serveCommand,
+	"convert": convertCommand,
+	"lint":    lintCommand,
+}
+
+// parseArguments tries to pass the arguments in args. Commands can register
+// additional flags using extra.
+// It will return the non-flag arguments, and any error encountered
+func parseArguments(args []string, extra ...func(flagset *flag.FlagSet)) ([]string, error) {
 	printVersion := false
 
 	// We do our own flagset to be able to test arguments.
 	flagset := flag.NewFlagSet(args[0], flag.ContinueOnError)
 	flagset.Usage = func() {
-		fmt.Fprintf(flagset.Output(), "Usage of %s [flags] /path/to/zone/file:\n", os.Args[0])
+		fmt.Fprintf(flagset.Output(), "Usage of %s [flags] /path/to/zone/file|directory|axfr://server/zone...:\n", os.Args[0])
 		flagset.PrintDefaults()
 	}
 	flagset.SetOutput(stderr)
@@ -60,209 +123,148 @@ func parseArguments(args []string) (string, error) {
 	flagset.BoolVar(&leaveUnknown, "leaveunknown", false, "Don't delete unknown records")
 	flagset.BoolVar(&ignoreSpf, "ignorespf", false, "Ignore SPF RR type (Not supported by this tool; use TXT for SPF records)")
 	flagset.BoolVar(&ignoreSrv, "ignoresrv", false, "Ignore SRV RR type (Not supported by this tool)")
+	flagset.StringVar(&ignoreFile, "ignorefile", "", "Read ignore rules from `file` (default "+ignoreFileName+" next to the zone file)")
 	flagset.StringVar(&origin, "origin", "", "Specify origin to resolve '@' at the top level")
 	flagset.IntVar(&zoneAutoTTL, "autottl", 0, "Specify TTL to interpret as Cloudflare automatic")
 	flagset.IntVar(&zoneCacheTTL, "cachettl", 1, "Specify TTL to interpret as Cloudflare caching")
+	flagset.StringVar(&owner, "owner", "", "Mark created records as owned by `id` and only delete owned records")
+	flagset.IntVar(&maxDeletes, "max-deletes", -1, "Abort if more than `N` records would be deleted")
+	flagset.Float64Var(&maxChangePercent, "max-change-percent", -1, "Abort if more than `P` percent of the existing records would be deleted or updated")
+	flagset.BoolVar(&force, "force", false, "Sync even if -max-deletes or -max-change-percent is exceeded")
+	protectRules = ignoreRules{}
+	flagset.Var(&protectRules, "protect", "Refuse to delete or change records matching `rule` (can be repeated)")
+	flagset.BoolVar(&allowProtected, "allow-protected", false, "Allow deleting and changing protected records")
+	flagset.DurationVar(&lockTTL, "lock-ttl", 10*time.Minute, "Consider locks older than `duration` stale")
+	flagset.DurationVar(&lockWait, "lock-wait", 0, "Wait up to `duration` for a lock held by someone else")
+	flagset.StringVar(&commit, "commit", gitCommit(), "Store `commit` as the git commit in the deployment metadata")
+	cnameFlattening = ""
+	flagset.Var(&cnameFlattening, "cname-flattening", "Set CNAME flattening of the zones to `mode` ("+flattenRoot+" or "+flattenAll+")")
+	accountFlags(flagset)
+	flagset.IntVar(&parallel, "parallel", 1, "Apply changes to up to `N` zones at a time")
+	flagset.StringVar(&providerName, "provider", cloudflareProviderName, "Sync zones to `provider` ("+cloudflareProviderName+" or "+rfc2136ProviderName+")")
+	flagset.StringVar(&server, "server", "", "Send RFC 2136 updates to the DNS server at `host[:port]`")
+	flagset.StringVar(&tsigName, "tsig-key", "", "Sign RFC 2136 updates and zone transfers with the TSIG key called `name`")
+	flagset.StringVar(&tsigAlgorithm, "tsig-algorithm", dns.HmacSHA256, "Use `algorithm` for TSIG signatures")
+	flagset.StringVar(&tsigSecret, "tsig-secret", "", "Use the base64 encoded `secret` for TSIG signatures")
 	flagset.BoolVar(&printVersion, "version", false, "Print version")
 
-	err := flagset.Parse(args[1:])
+	for _, register := range extra {
+		register(flagset)
+	}
+
+	// Flags are allowed between the zone files.
+	paths := []string{}
+	rest := args[1:]
+	err := flagset.Parse(rest)
+	for err == nil && flagset.NArg() > 0 {
+		paths = append(paths, flagset.Arg(0))
+		rest = flagset.Args()[1:]
+		err = flagset.Parse(rest)
+	}
+
+	if err == nil {
+		err = applyConfig(flagset)
+		if err != nil {
+			fmt.Fprintln(flagset.Output(), err)
+		}
+	}
+
+	if err == nil {
+		err = applyProviderDefaults(flagset)
+		if err != nil {
+			fmt.Fprintln(flagset.Output(), err)
+		}
+	}
 
 	if printVersion {
 		fmt.Printf("%d\n", version)
 		exit(0)
 	}
 
-	if err == nil && flagset.NArg() < 1 {
+	if err == nil && len(paths) < 1 {
 		err = errors.New("Zone file must be specified")
 		fmt.Fprintln(flagset.Output(), err)
 		flagset.Usage()
 	}
 
-	return flagset.Arg(0), err
+	return paths, err
 }
 
-func main() {
-	path, err := parseArguments(os.Args)
-	if err != nil {
-		os.Exit(1)
-	}
-
-	if apiKey == "" || apiEmail == "" {
-		fmt.Fprintf(stderr, "Please set CF_API_KEY and CF_API_EMAIL environment variables\n")
-		exit(1)
-	}
+// accountFlags will register the flags selecting the Cloudflare account,
+// the credentials and the profile in flagset.
+func accountFlags(flagset *flag.FlagSet) {
+	flagset.StringVar(&accountID, "account-id", "", "Only look for the zone in the account with ID `id`")
+	flagset.StringVar(&zoneID, "zone-id", "", "Use the zone with ID `id` instead of looking it up by name")
+	flagset.StringVar(&credentialFile, "credential-file", "", "Read the API token from `file`")
+	flagset.StringVar(&credentialCmd, "credential-cmd", "", "Get the credentials from the git-credential style helper `command`")
+	flagset.StringVar(&netrcPath, "netrc", "", "Read the credentials for "+cloudflareHost+" from the netrc `file`")
+	flagset.StringVar(&configPath, "config", defaultConfigFile, "Read profiles from `file`")
+	flagset.StringVar(&profileName, "profile", defaultProfile, "Use settings from profile `name`")
+}
 
-	f, err := os.Open(path)
-	if err != nil {
-		fmt.Fprintf(stderr, "Error opening '%s': %s\n", path, err.Error())
-		exit(1)
-	}
+func main() {
+	if len(os.Args) > 1 {
+		if command, found := commands[os.Args[1]]; found {
+			command(os.Args[1:])
 
-	hasher := sha256.New()
-	_, err = io.Copy(hasher, f)
-	if err != nil {
-		fmt.Fprintf(stderr, "Error reading '%s': %s\n", "path", err.Error())
-		exit(1)
+			return
+		}
 	}
 
-	_, err = f.Seek(0, 0)
+	paths, err := parseArguments(os.Args)
 	if err != nil {
-		fmt.Fprintf(stderr, "Error seeking '%s': %s\n", path, err.Error())
-		exit(1)
+		os.Exit(1)
 	}
 
-	zoneName, fileRecords, err := parseZoneWithOriginAndTTLs(f, origin, zoneAutoTTL, zoneCacheTTL)
+	err = loadCredentials()
 	if err != nil {
-		fmt.Fprintf(stderr, "Error reading '%s': %s\n", path, err.Error())
+		fmt.Fprintf(stderr, "%s\n", err.Error())
 		exit(1)
 	}
 
-	api, err := cloudflare.New(apiKey, apiEmail)
-	if err != nil {
-		fmt.Fprintf(stderr, "Error contacting Cloudflare: %s\n", err.Error())
+	if providerName == cloudflareProviderName && apiToken == "" && (apiKey == "" || apiEmail == "") {
+		fmt.Fprintf(stderr, "Please set CF_API_TOKEN, or CF_API_KEY and CF_API_EMAIL environment variables\n")
 		exit(1)
 	}
 
-	id, err := api.ZoneIDByName(zoneName)
+	paths, err = expandPaths(paths)
 	if err != nil {
-		fmt.Fprintf(stderr, "Can't get zone ID for '%s': %s\n", zoneName, err.Error())
+		fmt.Fprintf(stderr, "%s\n", err.Error())
 		exit(1)
 	}
 
-	allRecords, err := api.DNSRecords(id, cloudflare.DNSRecord{})
-	if err != nil {
-		fmt.Fprintf(stderr, "Can't get zone records for '%s': %s\n", id, err.Error())
+	if len(paths) == 0 {
+		fmt.Fprintf(stderr, "No zone files found\n")
 		exit(1)
 	}
-	var records = make([]cloudflare.DNSRecord, 0, len(allRecords))
-	for _, record := range allRecords {
-		if record.Type == "SRV" && ignoreSrv {
-			continue
-		}
-		if record.Type == "SPF" && ignoreSpf {
-			continue
-		}
-		records = append(records, record)
-	}
-	existingRecords := recordCollection(records)
-
-	versionRecord := cloudflare.DNSRecord{
-		Name:    "cfzone-version." + zoneName,
-		Content: strconv.Itoa(version),
-		Type:    "TXT",
-		TTL:     600,
-	}
-
-	n, versionRecordFound := existingRecords.Find(versionRecord, Updatable)
-	if versionRecordFound != nil {
-		deployedVersion, _ := strconv.Atoi(versionRecordFound.Content)
-
-		// Check if we risk "downgrading" the cloudflare setup.
-		if deployedVersion > version {
-			fmt.Fprintf(stdout,
-				"Deployed version (%d) is newer than current version (%d). Continue (y/N)? ",
-				deployedVersion,
-				version)
-
-			if !yesNo(stdin) {
-				fmt.Fprintf(stdout, "Aborting...\n")
-				exit(0)
-			}
-		}
-
-		existingRecords.Remove(n)
-	}
-
-	// Find records only present at cloudflare - and records only present in
-	// the file zone. This will be the basis for the add/delete collections.
-	addCandidates := fileRecords.Difference(existingRecords, FullMatch)
-	deleteCandidates := existingRecords.Difference(fileRecords, FullMatch)
-
-	// If we find the intersection between file and existing, we should have
-	// a list of records to update. We use only Updatable here, because that
-	// will give us a collection of records that makes sense to update.
-	updates := deleteCandidates.Intersect(addCandidates, Updatable)
-
-	// The records to be updated can be removed from the add and delete
-	// collections.
-	adds := addCandidates.Difference(updates, Updatable)
-	deletes := deleteCandidates.Difference(updates, Updatable)
-
-	if len(deletes) > 0 && leaveUnknown {
-		fmt.Fprintf(stdout, "%d unknown records left untouched\n", len(deletes))
-		deletes = deletes[:0]
-	}
-
-	numChanges := len(updates) + len(adds) + len(deletes)
-
-	if numChanges > 0 && !yes {
-		if len(deletes) > 0 {
-			fmt.Fprintf(stdout, "Records to delete:\n")
-			deletes.Fprint(stdout)
-			fmt.Printf("\n")
-		}
-
-		if len(adds) > 0 {
-			fmt.Fprintf(stdout, "Records to add:\n")
-			adds.Fprint(stdout)
-			fmt.Printf("\n")
-		}
-
-		if len(updates) > 0 {
-			fmt.Fprintf(stdout, "Records to update:\n")
-			updates.Fprint(stdout)
-			fmt.Printf("\n")
-		}
-
-		fmt.Fprintf(stdout, "Summary:\n")
-		fmt.Fprintf(stdout, "SHA256 zone checksum: %x\n", hasher.Sum(nil))
-		fmt.Fprintf(stdout, "Records to delete: %d\n", len(deletes))
-		fmt.Fprintf(stdout, "Records to add: %d\n", len(adds))
-		fmt.Fprintf(stdout, "Records to update: %d\n", len(updates))
-		fmt.Fprintf(stdout, "Unchanged records: %d\n", len(records)-len(deleteCandidates))
-
-		fmt.Fprintf(stdout, "%d change(s). Continue (y/N)? ", numChanges)
 
-		if !yesNo(stdin) {
-			fmt.Fprintf(stdout, "Aborting...\n")
-			exit(0)
+	files := make([]*zoneFile, 0, len(paths))
+	for _, path := range paths {
+		zf, err := loadZone(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s\n", err.Error())
+			exit(1)
 		}
-	}
 
-	// We sneak this in after informing the user about updates to avoid
-	// polluting the diff and confusing the user.
-	if versionRecordFound != nil {
-		if versionRecordFound.Content != versionRecord.Content {
-			versionRecordFound.Content = versionRecord.Content
-
-			updates = append(updates, *versionRecordFound)
-		}
-	} else {
-		adds = append(addCandidates, versionRecord)
+		files = append(files, zf)
 	}
 
-	for _, r := range deletes {
-		err = api.DeleteDNSRecord(id, r.ID)
-		if err != nil {
-			fmt.Fprintf(stderr, "Failed to delete record %+v: %s\n", r, err.Error())
-			exit(1)
-		}
+	// Files for the same zone are fragments of that zone.
+	files, err = mergeZoneFiles(files)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err.Error())
+		exit(1)
 	}
 
-	for _, r := range adds {
-		_, err = api.CreateDNSRecord(id, r)
-		if err != nil {
-			fmt.Fprintf(stderr, "Failed to add record %+v: %s\n", r, err.Error())
-			exit(1)
-		}
+	connect, err := newConnector()
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err.Error())
+		exit(1)
 	}
 
-	for _, r := range updates {
-		err = api.UpdateDNSRecord(id, r.ID, r)
-		if err != nil {
-			fmt.Fprintf(stderr, "Failed to update record %+v: %s\n", r, err.Error())
-			exit(1)
-		}
+	code := syncZones(connect, files)
+	if code != 0 {
+		exit(code)
 	}
 }
 
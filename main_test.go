@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -42,11 +43,13 @@ func TestBrokenFlag(t *testing.T) {
 func TestParseArguments(t *testing.T) {
 	cases := []struct {
 		in       []string
-		expected string
+		expected []string
 	}{
-		{[]string{"./test", "-yes", "path1"}, "path1"},
-		{[]string{"./test", "path2"}, "path2"},
-		{[]string{"./test", "path3", "-yes"}, "path3"},
+		{[]string{"./test", "-yes", "path1"}, []string{"path1"}},
+		{[]string{"./test", "path2"}, []string{"path2"}},
+		{[]string{"./test", "path3", "-yes"}, []string{"path3"}},
+		{[]string{"./test", "path4", "path5"}, []string{"path4", "path5"}},
+		{[]string{"./test", "path6", "-yes", "path7"}, []string{"path6", "path7"}},
 	}
 
 	for i, c := range cases {
@@ -56,8 +59,8 @@ func TestParseArguments(t *testing.T) {
 			t.Errorf("%d: %s", i, err.Error())
 		}
 
-		if result != c.expected {
-			t.Errorf("%d: parseArguments() did not return expected path for %+v. Got %s, expected %s", i, c.in, result, c.expected)
+		if !reflect.DeepEqual(result, c.expected) {
+			t.Errorf("%d: parseArguments() did not return expected paths for %+v. Got %v, expected %v", i, c.in, result, c.expected)
 		}
 	}
 }
@@ -65,6 +68,7 @@ func TestParseArguments(t *testing.T) {
 func TestMissingKey(t *testing.T) {
 	defer expectExit(t, 1)
 
+	apiToken = ""
 	apiKey = ""
 	apiEmail = ""
 
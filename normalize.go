@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Normalize will return r in the canonical form used for comparing records
+// from zone files and providers. Names and targets are lowercased without
+// the trailing dot and in punycode, and IP addresses are formatted
+// canonically. TXT content is the raw value already and left as is.
+func (r Record) Normalize() Record {
+	r.Name = normalizeName(r.Name)
+	r.Type = strings.ToUpper(r.Type)
+
+	switch r.Type {
+	case "A":
+		if ip := net.ParseIP(r.Content).To4(); ip != nil {
+			r.Content = ip.String()
+		}
+
+	case "AAAA":
+		// IPv4-mapped addresses would be formatted as IPv4 addresses.
+		if ip := net.ParseIP(r.Content); ip != nil && ip.To4() == nil {
+			r.Content = ip.String()
+		} else {
+			r.Content = strings.ToLower(r.Content)
+		}
+
+	case "CNAME", "MX":
+		r.Content = normalizeName(r.Content)
+	}
+
+	return r
+}
+
+// Normalize will return a copy of c with all records normalized.
+func (c recordCollection) Normalize() recordCollection {
+	result := make(recordCollection, 0, len(c))
+
+	for _, r := range c {
+		result = append(result, r.Normalize())
+	}
+
+	return result
+}
+
+// normalizeName will return name lowercased without the trailing dot. Unicode
+// labels are converted to punycode, like Cloudflare stores them.
+func normalizeName(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+	ascii, err := idna.Punycode.ToASCII(name)
+	if err != nil {
+		return name
+	}
+
+	return ascii
+}
+
+// unicodeName will return name with punycode labels converted to Unicode,
+// or an empty string if name has no punycode labels.
+func unicodeName(name string) string {
+	u, err := idna.Punycode.ToUnicode(name)
+	if err != nil || u == name {
+		return ""
+	}
+
+	return u
+}
+
+// joinTXT will join s if it's made up of quoted strings like
+// "v=DKIM1; p=MIIB" "IjANBgkqh", and return false otherwise.
+func joinTXT(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, `"`) {
+		return "", false
+	}
+
+	result := ""
+	for s != "" {
+		if s[0] != '"' {
+			return "", false
+		}
+
+		// Find the closing quote, skipping escaped characters.
+		end := -1
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+
+				continue
+			}
+
+			if s[i] == '"' {
+				end = i
+
+				break
+			}
+		}
+
+		if end < 0 {
+			return "", false
+		}
+
+		result += unescapeTXT(s[1:end])
+		s = strings.TrimLeft(s[end+1:], " \t")
+	}
+
+	return result, true
+}
+
+// unescapeTXT will undo the escaping of a character string in the BIND
+// presentation format.
+func unescapeTXT(s string) string {
+	result := []byte{}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			if i+3 < len(s) && isDigits(s[i+1:i+4]) {
+				n, _ := strconv.Atoi(s[i+1 : i+4])
+				if n < 256 {
+					result = append(result, byte(n))
+					i += 3
+
+					continue
+				}
+			}
+
+			i++
+		}
+
+		result = append(result, s[i])
+	}
+
+	return string(result)
+}
+
+// isDigits will return true if s is made up of ASCII digits only.
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// escapeTXT will escape s as a character string in the BIND presentation
+// format.
+func escapeTXT(s string) string {
+	result := []byte{}
+
+	for i := 0; i < len(s); i++ {
+		switch b := s[i]; {
+		case b == '"' || b == '\\':
+			result = append(result, '\\', b)
+
+		case b < ' ' || b == 0x7f:
+			result = append(result, []byte(fmt.Sprintf("\\%03d", b))...)
+
+		default:
+			result = append(result, b)
+		}
+	}
+
+	return string(result)
+}
+
+// quoteTXT will quote s as a character string in the BIND presentation
+// format.
+func quoteTXT(s string) string {
+	return `"` + escapeTXT(s) + `"`
+}
+
+// splitTXT will split s into strings of at most 255 bytes, the maximum
+// length of a string in a TXT record.
+func splitTXT(s string) []string {
+	result := []string{}
+
+	for len(s) > 255 {
+		result = append(result, s[:255])
+		s = s[255:]
+	}
+
+	return append(result, s)
+}
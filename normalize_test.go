@@ -0,0 +1,216 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		a Record
+		b Record
+	}{
+		{Record{Type: "A", Name: "WWW.Example.COM", Content: "127.0.0.1"}, Record{Type: "A", Name: "www.example.com", Content: "127.0.0.1"}},
+		{Record{Type: "A", Name: "www.example.com.", Content: "127.0.0.1"}, Record{Type: "A", Name: "www.example.com", Content: "127.0.0.1"}},
+		{Record{Type: "a", Name: "www.example.com", Content: "127.0.0.1"}, Record{Type: "A", Name: "www.example.com", Content: "127.0.0.1"}},
+		{Record{Type: "A", Name: "www.example.com", Content: "::ffff:127.0.0.1"}, Record{Type: "A", Name: "www.example.com", Content: "127.0.0.1"}},
+		{Record{Type: "AAAA", Name: "www.example.com", Content: "2001:DB8:0:0:0:0:0:1"}, Record{Type: "AAAA", Name: "www.example.com", Content: "2001:db8::1"}},
+		{Record{Type: "AAAA", Name: "www.example.com", Content: "2001:db8:0:0:1:0:0:1"}, Record{Type: "AAAA", Name: "www.example.com", Content: "2001:db8::1:0:0:1"}},
+		{Record{Type: "AAAA", Name: "www.example.com", Content: "::FFFF:127.0.0.1"}, Record{Type: "AAAA", Name: "www.example.com", Content: "::ffff:127.0.0.1"}},
+		{Record{Type: "CNAME", Name: "www.example.com", Content: "Example.COM."}, Record{Type: "CNAME", Name: "www.example.com", Content: "example.com"}},
+		{Record{Type: "MX", Name: "example.com", Content: "Mail.Example.com.", Priority: 10}, Record{Type: "MX", Name: "example.com", Content: "mail.example.com", Priority: 10}},
+	}
+
+	for i, in := range cases {
+		a := in.a.Normalize()
+		b := in.b.Normalize()
+
+		if !FullMatch(a, b) {
+			t.Errorf("%d: %+v and %+v are not equivalent", i, a, b)
+		}
+	}
+}
+
+func TestNormalizeKeepsContent(t *testing.T) {
+	cases := []Record{
+		{Type: "TXT", Name: "example.com", Content: `Case Matters`},
+		{Type: "TXT", Name: "example.com", Content: `"unterminated`},
+		{Type: "TXT", Name: "example.com", Content: `"quoted" and not`},
+		{Type: "TXT", Name: "example.com", Content: `"quoted"`},
+		{Type: "TXT", Name: "example.com", Content: `"v=DKIM1; k=rsa; " "p=MIIB"`},
+		{Type: "A", Name: "example.com", Content: "not an address"},
+	}
+
+	for i, in := range cases {
+		if in.Normalize().Content != in.Content {
+			t.Errorf("%d: Normalize() changed '%s' to '%s'", i, in.Content, in.Normalize().Content)
+		}
+	}
+}
+
+func TestNormalizeZoneFile(t *testing.T) {
+	zone := `$ORIGIN example.com.
+@ 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 3600 600 86400 300
+WWW 300 IN CNAME Example.COM.
+dkim 300 IN TXT "v=DKIM1; k=rsa; " "p=MIIB\"quoted\""
+`
+
+	z, err := parseZoneFile(strings.NewReader(zone), "", cfAutoTTL, cfCacheTTL)
+	if err != nil {
+		t.Fatalf("parseZoneFile() returned error: %s", err.Error())
+	}
+
+	remote := recordCollection{
+		{ID: "1", Type: "CNAME", Name: "www.example.com", Content: "example.com", TTL: 300},
+		{ID: "2", Type: "TXT", Name: "dkim.example.com", Content: `v=DKIM1; k=rsa; p=MIIB"quoted"`, TTL: 300},
+	}
+
+	local := z.Records.Normalize()
+	if len(local.Difference(remote.Normalize(), FullMatch)) != 0 {
+		t.Errorf("zone file and remote records differ after normalizing: %+v", local)
+	}
+}
+
+func TestNormalizeQuotedTXT(t *testing.T) {
+	zone := `$ORIGIN example.com.
+@ 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 3600 600 86400 300
+txt 300 IN TXT "\"quoted\""
+`
+
+	z, err := parseZoneFile(strings.NewReader(zone), "", cfAutoTTL, cfCacheTTL)
+	if err != nil {
+		t.Fatalf("parseZoneFile() returned error: %s", err.Error())
+	}
+
+	local := z.Records.Normalize()
+	if len(local) != 1 || local[0].Content != `"quoted"` {
+		t.Errorf("Normalize() changed the quoted TXT value, got %+v", local)
+	}
+}
+
+func TestComputeNormalized(t *testing.T) {
+	provider := &fakeProvider{
+		records: recordCollection{
+			{ID: "a", Type: "A", Name: "WWW.example.com", Content: "127.0.0.1", TTL: 300},
+			{ID: "b", Type: "AAAA", Name: "www.example.com", Content: "2001:DB8:0::1", TTL: 300},
+		},
+	}
+
+	zf := &zoneFile{
+		Paths: []string{"example.com"},
+		Zone: &parsedZone{
+			Name: "example.com",
+			Records: recordCollection{
+				{Type: "A", Name: "www.example.com", Content: "127.0.0.1", TTL: 300},
+				{Type: "AAAA", Name: "www.example.com", Content: "2001:db8::1", TTL: 300},
+			},
+		},
+		Options: options{MaxDeletes: -1, MaxChangePercent: -1},
+	}
+
+	p, err := planZone(fakeConnector(provider), zf)
+	if err != nil {
+		t.Fatalf("planZone() returned error: %s", err.Error())
+	}
+	defer p.Release()
+
+	if p.Changes() != 0 {
+		t.Errorf("planZone() found %d changes in equivalent records", p.Changes())
+	}
+}
+
+func TestUnescapeTXT(t *testing.T) {
+	cases := map[string]string{
+		`plain`:            "plain",
+		`say \"hi\"`:       `say "hi"`,
+		`back\\slash`:      `back\slash`,
+		`tab\009`:          "tab\t",
+		`trailing\`:        `trailing\`,
+		`digits\12`:        "digits12",
+		`sign\-12`:         "sign-12",
+		`sign\+12`:         "sign+12",
+		`short\1a2`:        "short1a2",
+		`v=DKIM1\; k=rsa;`: "v=DKIM1; k=rsa;",
+	}
+
+	for in, expected := range cases {
+		if unescapeTXT(in) != expected {
+			t.Errorf("unescapeTXT(%s) returned '%s', expected '%s'", in, unescapeTXT(in), expected)
+		}
+	}
+}
+
+func TestEscapeTXT(t *testing.T) {
+	cases := map[string]string{
+		"plain":       "plain",
+		`say "hi"`:    `say \"hi\"`,
+		`back\slash`:  `back\\slash`,
+		"tab\t":       `tab\009`,
+		"line\nbreak": `line\010break`,
+	}
+
+	for in, expected := range cases {
+		if escapeTXT(in) != expected {
+			t.Errorf("escapeTXT(%q) returned '%s', expected '%s'", in, escapeTXT(in), expected)
+		}
+
+		if unescapeTXT(escapeTXT(in)) != in {
+			t.Errorf("unescapeTXT() did not reverse escapeTXT(%q)", in)
+		}
+	}
+}
+
+func TestNormalizeIDN(t *testing.T) {
+	cases := []struct {
+		a Record
+		b Record
+	}{
+		{Record{Type: "A", Name: "rødgrød.blåbær.dk", Content: "127.0.0.1"}, Record{Type: "A", Name: "xn--rdgrd-vuad.xn--blbr-roah.dk", Content: "127.0.0.1"}},
+		{Record{Type: "A", Name: "RØDGRØD.Blåbær.dk.", Content: "127.0.0.1"}, Record{Type: "A", Name: "xn--rdgrd-vuad.xn--blbr-roah.dk", Content: "127.0.0.1"}},
+		{Record{Type: "A", Name: "_dmarc.blåbær.dk", Content: "127.0.0.1"}, Record{Type: "A", Name: "_dmarc.xn--blbr-roah.dk", Content: "127.0.0.1"}},
+		{Record{Type: "CNAME", Name: "www.blåbær.dk", Content: "Æble.dk."}, Record{Type: "CNAME", Name: "www.xn--blbr-roah.dk", Content: "xn--ble-xla.dk"}},
+	}
+
+	for i, in := range cases {
+		a := in.a.Normalize()
+		b := in.b.Normalize()
+
+		if !FullMatch(a, b) {
+			t.Errorf("%d: %+v and %+v are not equivalent", i, a, b)
+		}
+	}
+}
+
+func TestFprintIDN(t *testing.T) {
+	c := recordCollection{
+		Record{Type: "CNAME", Name: "www.xn--blbr-roah.dk", Content: "xn--ble-xla.dk", TTL: 300},
+	}
+
+	var b strings.Builder
+	c.Fprint(&b)
+
+	expected := "www.xn--blbr-roah.dk. 300 IN CNAME xn--ble-xla.dk ; www.blåbær.dk, target æble.dk\n"
+	if b.String() != expected {
+		t.Errorf("Fprint() returned [%s], expected [%s]", b.String(), expected)
+	}
+}
+
+func TestLintIDNDuplicates(t *testing.T) {
+	zone := `$ORIGIN blåbær.dk.
+@ 3600 IN SOA ns1 hostmaster 1 3600 600 86400 300
+rødgrød 300 IN A 127.0.0.1
+xn--rdgrd-vuad.xn--blbr-roah.dk. 300 IN A 127.0.0.1
+`
+
+	z, err := parseZoneFile(strings.NewReader(zone), "", cfAutoTTL, cfCacheTTL)
+	if err != nil {
+		t.Fatalf("parseZoneFile() returned error: %s", err.Error())
+	}
+
+	z.Records = z.Records.Normalize()
+
+	findings := lintDuplicates(z)
+	if len(findings) != 1 || findings[0].Record.Line != 4 {
+		t.Errorf("lintDuplicates() did not find the mixed duplicate, got %v", findings)
+	}
+}
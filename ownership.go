@@ -0,0 +1,144 @@
+package main
+
+import (
+	"strings"
+)
+
+const (
+	// ownerPrefix is prepended to the name of a managed record to form the
+	// name of the TXT record marking the ownership.
+	ownerPrefix = "cfzone-owner."
+
+	// heritage is the first part of the content of all ownership markers.
+	// This is similar to how external-dns marks records.
+	heritage = "heritage=cfzone"
+)
+
+// ownerKey identifies a set of records owned by a cfzone instance.
+type ownerKey struct {
+	Name string
+	Type string
+}
+
+// ownerRecordName returns the name of the ownership marker for a record
+// named name. A wildcard label is replaced, because Cloudflare only accepts
+// the asterisk as the leftmost label.
+func ownerRecordName(name string) string {
+	return ownerPrefix + strings.Replace(name, "*", "_wildcard", 1)
+}
+
+// ownerRecordContent returns the content of an ownership marker for owner
+// and the record type typ.
+func ownerRecordContent(owner string, typ string) string {
+	return heritage + ",cfzone/owner=" + owner + ",cfzone/type=" + typ
+}
+
+// newOwnerRecord will instantiate an ownership marker for the records in
+// the zone matching key.
+func newOwnerRecord(key ownerKey, owner string) Record {
+	return Record{
+		Name:    ownerRecordName(key.Name),
+		Content: ownerRecordContent(owner, key.Type),
+		Type:    "TXT",
+		TTL:     600,
+	}
+}
+
+// isOwnerRecord will return true if r is an ownership marker created by any
+// cfzone instance.
+func isOwnerRecord(r Record) bool {
+	return r.Type == "TXT" &&
+		strings.HasPrefix(r.Name, ownerPrefix) &&
+		strings.HasPrefix(r.Content, heritage+",")
+}
+
+// ownerKeys will return the distinct keys for all records in c in the order
+// they first appear.
+func (c recordCollection) ownerKeys() []ownerKey {
+	seen := make(map[ownerKey]bool, len(c))
+	keys := make([]ownerKey, 0, len(c))
+
+	for _, r := range c {
+		key := ownerKey{Name: r.Name, Type: r.Type}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// isOwnedBy will return true if the ownership marker r belongs to owner.
+func isOwnedBy(r Record, owner string) bool {
+	return strings.Contains(r.Content+",", ",cfzone/owner="+owner+",")
+}
+
+// MarkerMatch will match ownership markers by name and content. TTL is
+// ignored, it has no meaning for a marker.
+func MarkerMatch(a Record, b Record) bool {
+	return a.Type == b.Type && a.Name == b.Name && a.Content == b.Content
+}
+
+// owns will return true if c contains a marker for r owned by owner.
+func (c recordCollection) owns(r Record, owner string) bool {
+	marker := newOwnerRecord(ownerKey{Name: r.Name, Type: r.Type}, owner)
+
+	n, _ := c.Find(marker, MarkerMatch)
+
+	return n >= 0
+}
+
+// splitOwned will split c into records owned by owner and records not owned
+// by owner. markers must be the ownership markers present in the zone.
+func (c recordCollection) splitOwned(markers recordCollection, owner string) (recordCollection, recordCollection) {
+	owned := recordCollection{}
+	unowned := recordCollection{}
+
+	for _, r := range c {
+		if markers.owns(r, owner) {
+			owned = append(owned, r)
+		} else {
+			unowned = append(unowned, r)
+		}
+	}
+
+	return owned, unowned
+}
+
+// ownerChanges will compute the ownership markers to add and delete for
+// owner. c must be the ownership markers present in the zone, managed the
+// records which must be marked as owned after syncing and remaining all
+// records left in the zone after syncing. Markers for remaining records are
+// kept, markers belonging to other owners are never touched.
+func (c recordCollection) ownerChanges(owner string, managed recordCollection, remaining recordCollection) (recordCollection, recordCollection) {
+	adds := recordCollection{}
+	deletes := recordCollection{}
+
+	keep := recordCollection{}
+	for _, key := range remaining.ownerKeys() {
+		keep = append(keep, newOwnerRecord(key, owner))
+	}
+
+	for _, key := range managed.ownerKeys() {
+		marker := newOwnerRecord(key, owner)
+
+		if n, _ := c.Find(marker, MarkerMatch); n < 0 {
+			adds = append(adds, marker)
+		}
+
+		keep = append(keep, marker)
+	}
+
+	for _, marker := range c {
+		if !isOwnedBy(marker, owner) {
+			continue
+		}
+
+		if n, _ := keep.Find(marker, MarkerMatch); n < 0 {
+			deletes = append(deletes, marker)
+		}
+	}
+
+	return adds, deletes
+}
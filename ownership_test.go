@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOwnerRecordName(t *testing.T) {
+	cases := []struct {
+		in       string
+		expected string
+	}{
+		{"example.com", "cfzone-owner.example.com"},
+		{"www.example.com", "cfzone-owner.www.example.com"},
+		{"*.apps.example.com", "cfzone-owner._wildcard.apps.example.com"},
+	}
+
+	for i, in := range cases {
+		result := ownerRecordName(in.in)
+		if result != in.expected {
+			t.Errorf("%d: ownerRecordName() returned wrong name for '%s', got %s, expected %s", i, in.in, result, in.expected)
+		}
+	}
+}
+
+func TestIsOwnerRecord(t *testing.T) {
+	cases := []struct {
+		in       Record
+		expected bool
+	}{
+		{newOwnerRecord(ownerKey{Name: "a1", Type: "A"}, "prod"), true},
+		{Record{Type: "TXT", Name: "cfzone-owner.a1", Content: "heritage=external-dns"}, false},
+		{Record{Type: "TXT", Name: "a1", Content: ownerRecordContent("prod", "A")}, false},
+		{Record{Type: "A", Name: "cfzone-owner.a1", Content: "127.0.0.1"}, false},
+	}
+
+	for i, in := range cases {
+		result := isOwnerRecord(in.in)
+		if result != in.expected {
+			t.Errorf("%d: isOwnerRecord() returned wrong result for %+v, got %v, expected %v", i, in.in, result, in.expected)
+		}
+	}
+}
+
+func TestSplitOwned(t *testing.T) {
+	a1 := Record{Type: "A", Name: "a1", Content: "127.0.0.1"}
+	a2 := Record{Type: "A", Name: "a2", Content: "127.0.0.2"}
+	txt := Record{Type: "TXT", Name: "a1", Content: "hello"}
+	markers := recordCollection{
+		newOwnerRecord(ownerKey{Name: "a1", Type: "A"}, "prod"),
+		newOwnerRecord(ownerKey{Name: "a2", Type: "A"}, "staging"),
+	}
+
+	owned, unowned := recordCollection{a1, a2, txt}.splitOwned(markers, "prod")
+
+	if !reflect.DeepEqual(owned, recordCollection{a1}) {
+		t.Errorf("splitOwned() returned wrong owned records: %+v", owned)
+	}
+
+	if !reflect.DeepEqual(unowned, recordCollection{a2, txt}) {
+		t.Errorf("splitOwned() returned wrong unowned records: %+v", unowned)
+	}
+}
+
+func TestOwnerChanges(t *testing.T) {
+	a1 := Record{Type: "A", Name: "a1", Content: "127.0.0.1"}
+	a2 := Record{Type: "A", Name: "a2", Content: "127.0.0.2"}
+	a3 := Record{Type: "A", Name: "a3", Content: "127.0.0.3"}
+	m1 := newOwnerRecord(ownerKey{Name: "a1", Type: "A"}, "prod")
+	m2 := newOwnerRecord(ownerKey{Name: "a2", Type: "A"}, "prod")
+	m3 := newOwnerRecord(ownerKey{Name: "a3", Type: "A"}, "prod")
+	other := newOwnerRecord(ownerKey{Name: "a4", Type: "A"}, "staging")
+	empty := recordCollection{}
+
+	cases := []struct {
+		markers   recordCollection
+		managed   recordCollection
+		remaining recordCollection
+		adds      recordCollection
+		deletes   recordCollection
+	}{
+		{empty, empty, empty, empty, empty},
+		{empty, recordCollection{a1, a1, a2}, empty, recordCollection{m1, m2}, empty},
+		{recordCollection{m1}, recordCollection{a1, a2}, empty, recordCollection{m2}, empty},
+		{recordCollection{m1, m2}, recordCollection{a1}, empty, empty, recordCollection{m2}},
+		{recordCollection{m1, m2}, recordCollection{a1}, recordCollection{a2}, empty, empty},
+		{recordCollection{m3, other}, recordCollection{a1}, empty, recordCollection{m1}, recordCollection{m3}},
+		{recordCollection{m3}, recordCollection{a3}, empty, empty, empty},
+	}
+
+	for i, in := range cases {
+		adds, deletes := in.markers.ownerChanges("prod", in.managed, in.remaining)
+		if !reflect.DeepEqual(adds, in.adds) {
+			t.Errorf("%d: ownerChanges() returned wrong adds, got %+v, expected %+v", i, adds, in.adds)
+		}
+
+		if !reflect.DeepEqual(deletes, in.deletes) {
+			t.Errorf("%d: ownerChanges() returned wrong deletes, got %+v, expected %+v", i, deletes, in.deletes)
+		}
+	}
+}
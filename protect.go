@@ -0,0 +1,59 @@
+package main
+
+// protectAnnotation marks a record in a zone file as protected when present
+// in the comment following the record.
+const protectAnnotation = "cfzone:protected"
+
+// protection describes the records which must never be deleted or changed.
+type protection struct {
+	// Rules matches protected records at Cloudflare.
+	Rules ignoreRules
+
+	// Annotated holds records annotated as protected in the zone file. All
+	// records with the same name and type are protected.
+	Annotated recordCollection
+}
+
+// IDMatch will match records by their Cloudflare ID.
+func IDMatch(a Record, b Record) bool {
+	return a.ID == b.ID
+}
+
+// Protects will return true if r is protected.
+func (p protection) Protects(r Record) bool {
+	if p.Rules.Match(r) {
+		return true
+	}
+
+	n, _ := p.Annotated.Find(r, Updatable)
+
+	return n >= 0
+}
+
+// Records will return the protected records from c.
+func (p protection) Records(c recordCollection) recordCollection {
+	result := recordCollection{}
+
+	for _, r := range c {
+		if p.Protects(r) {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
+
+// Violations will return the records from existing which would be deleted
+// or changed by applying deletes and updates, while being protected.
+func (p protection) Violations(deletes recordCollection, updates recordCollection, existing recordCollection) recordCollection {
+	result := p.Records(deletes)
+
+	for _, u := range updates {
+		_, r := existing.Find(u, IDMatch)
+		if r != nil && p.Protects(*r) {
+			result = append(result, *r)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseZoneProtected(t *testing.T) {
+	zone := `$ORIGIN example.com.
+@    86400    IN SOA ns1.example.com. hostmaster.example.com. 2015071700 86400 7200 604800 86400
+@     1800 IN MX 10 mail10.example.com. ; cfzone:protected
+www   1800 IN CNAME example.com. ; just a comment
+test1 1800 IN A 127.0.0.1
+`
+
+	z, err := parseZoneFile(strings.NewReader(zone), "", cfAutoTTL, cfCacheTTL)
+	if err != nil {
+		t.Fatalf("parseZoneFile() returned error: %s", err.Error())
+	}
+
+	expected := recordCollection{
+		Record{Type: "MX", Priority: 10, Name: "example.com", Content: "mail10.example.com", TTL: 1800, Comment: "cfzone:protected", Line: 3},
+	}
+
+	if !reflect.DeepEqual(z.Protected, expected) {
+		t.Errorf("parseZoneFile() returned wrong protected records, got:\n%s, expected:\n%s", zoneString(z.Protected), zoneString(expected))
+	}
+
+	if len(z.Records) != 3 {
+		t.Errorf("parseZoneFile() returned %d records, expected 3", len(z.Records))
+	}
+
+	if z.Records[1].Comment != "just a comment" {
+		t.Errorf("parseZoneFile() returned comment '%s', expected 'just a comment'", z.Records[1].Comment)
+	}
+
+	if len(z.Skipped) != 1 {
+		t.Errorf("parseZoneFile() returned %d skipped records, expected 1", len(z.Skipped))
+	}
+}
+
+func TestProtectionViolations(t *testing.T) {
+	mx := Record{ID: "1", Type: "MX", Name: "example.com", Content: "mail", Priority: 10}
+	mx2 := Record{ID: "2", Type: "MX", Name: "example.com", Content: "mail2", Priority: 20}
+	www := Record{ID: "3", Type: "CNAME", Name: "www.example.com", Content: "example.com"}
+	verification := Record{ID: "4", Type: "TXT", Name: "example.com", Content: "google-site-verification=abc"}
+	spf := Record{ID: "5", Type: "TXT", Name: "example.com", Content: "v=spf1 -all"}
+	existing := recordCollection{mx, mx2, www, verification, spf}
+
+	var rules ignoreRules
+	err := rules.Set("type=TXT content=google-site-verification*")
+	if err != nil {
+		t.Fatalf("Set() returned error: %s", err.Error())
+	}
+
+	p := protection{
+		Rules:     rules,
+		Annotated: recordCollection{Record{Type: "MX", Name: "example.com", Content: "mail", Priority: 10}},
+	}
+
+	wwwUpdate := www
+	wwwUpdate.Content = "example.net"
+	mxUpdate := mx
+	mxUpdate.Priority = 5
+	spfUpdate := spf
+	spfUpdate.Content = "v=spf1 mx -all"
+
+	empty := recordCollection{}
+
+	cases := []struct {
+		deletes  recordCollection
+		updates  recordCollection
+		expected recordCollection
+	}{
+		{empty, empty, empty},
+		{recordCollection{www}, recordCollection{wwwUpdate}, empty},
+		{recordCollection{mx2}, empty, recordCollection{mx2}},
+		{recordCollection{verification, spf}, empty, recordCollection{verification}},
+		{empty, recordCollection{mxUpdate, spfUpdate}, recordCollection{mx}},
+	}
+
+	for i, in := range cases {
+		result := p.Violations(in.deletes, in.updates, existing)
+		if !reflect.DeepEqual(result, in.expected) {
+			t.Errorf("%d: Violations() returned wrong result, got %+v, expected %+v", i, result, in.expected)
+		}
+	}
+
+	protected := p.Records(existing)
+	if !reflect.DeepEqual(protected, recordCollection{mx, mx2, verification}) {
+		t.Errorf("Records() returned wrong result: %+v", protected)
+	}
+}
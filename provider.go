@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+type (
+	// Provider is a DNS provider hosting a single zone.
+	Provider interface {
+		// Records will return the records in the zone. If Type or Name is
+		// set in filter, only matching records are returned.
+		Records(filter Record) (recordCollection, error)
+
+		// Create will create r and return it with the ID assigned by the
+		// provider.
+		Create(r Record) (Record, error)
+
+		// Update will replace the record with the ID of r by r.
+		Update(r Record) error
+
+		// Delete will delete r.
+		Delete(r Record) error
+
+		// Capabilities will return the features supported by the provider.
+		Capabilities() Capabilities
+	}
+
+	// Capabilities describes the features supported by a provider.
+	Capabilities struct {
+		// Proxied is true if records can be proxied by the provider.
+		Proxied bool
+
+		// AutoTTL is true if the provider can choose the TTL of a record.
+		AutoTTL bool
+
+		// Types lists the supported record types.
+		Types []string
+
+		// ApexCNAME is true if a CNAME record is allowed at the zone apex.
+		ApexCNAME bool
+	}
+
+	// connector will return the provider hosting the zone called zoneName.
+	connector func(zoneName string, opts options) (Provider, error)
+)
+
+// Supports will return an error if r uses a feature not in c.
+func (c Capabilities) Supports(r Record) error {
+	supported := false
+	for _, t := range c.Types {
+		if t == r.Type {
+			supported = true
+		}
+	}
+
+	if !supported {
+		return fmt.Errorf("record type %s is not supported by the provider", r.Type)
+	}
+
+	if r.Proxied && !c.Proxied {
+		return fmt.Errorf("proxied records are not supported by the provider")
+	}
+
+	if r.TTL == cfAutoTTL && !r.Proxied && !c.AutoTTL {
+		return fmt.Errorf("automatic TTL is not supported by the provider")
+	}
+
+	return nil
+}
+
+// Check will return an error for the first record in records using a
+// feature not in c.
+func (c Capabilities) Check(records recordCollection) error {
+	for _, r := range records {
+		err := c.Supports(r)
+		if err != nil {
+			return fmt.Errorf("%s %s: %s", r.Name, r.Type, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// filterMatch will return true if r matches filter as described for
+// Provider.Records.
+func filterMatch(r Record, filter Record) bool {
+	if filter.Type != "" && r.Type != filter.Type {
+		return false
+	}
+
+	if filter.Name != "" && r.Name != filter.Name {
+		return false
+	}
+
+	return true
+}
+
+// newConnector will return a connector for the provider selected by
+// -provider.
+func newConnector() (connector, error) {
+	switch providerName {
+	case cloudflareProviderName:
+		return newCloudflareConnector()
+
+	case rfc2136ProviderName:
+		return newRFC2136Connector(server, tsigKey{
+			Name:      tsigName,
+			Algorithm: tsigAlgorithm,
+			Secret:    tsigSecret,
+		})
+	}
+
+	return nil, fmt.Errorf("Unknown provider '%s'", providerName)
+}
+
+// applyProviderDefaults will adjust the defaults of flags in flagset for
+// the selected provider. Proxying and automatic TTLs are Cloudflare
+// features, other providers use the TTLs from the zone file as is.
+func applyProviderDefaults(flagset *flag.FlagSet) error {
+	switch providerName {
+	case cloudflareProviderName:
+		return nil
+
+	case rfc2136ProviderName:
+	default:
+		return fmt.Errorf("Unknown provider '%s'", providerName)
+	}
+
+	set := map[string]bool{}
+	flagset.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for _, name := range []string{"autottl", "cachettl"} {
+		if set[name] {
+			continue
+		}
+
+		err := flagset.Set(name, "-1")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
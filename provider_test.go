@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeProvider is an in-memory implementation of Provider.
+type fakeProvider struct {
+	records recordCollection
+	nextID  int
+
+	// onCreate is called after a record is created, if set.
+	onCreate func()
+
+	// failOn makes changes to records with this name fail, if set.
+	failOn string
+}
+
+func (f *fakeProvider) Records(filter Record) (recordCollection, error) {
+	result := recordCollection{}
+
+	for _, r := range f.records {
+		if filterMatch(r, filter) {
+			result = append(result, r)
+		}
+	}
+
+	return result, nil
+}
+
+func (f *fakeProvider) Create(r Record) (Record, error) {
+	if f.failOn != "" && r.Name == f.failOn {
+		return Record{}, errors.New("create failed")
+	}
+
+	f.nextID++
+	r.ID = strconv.Itoa(f.nextID)
+	f.records = append(f.records, r)
+
+	if f.onCreate != nil {
+		f.onCreate()
+	}
+
+	return r, nil
+}
+
+func (f *fakeProvider) Update(r Record) error {
+	n, _ := f.records.Find(r, IDMatch)
+	if n < 0 {
+		return errors.New("record not found")
+	}
+
+	f.records[n] = r
+
+	return nil
+}
+
+func (f *fakeProvider) Delete(r Record) error {
+	n, _ := f.records.Find(r, IDMatch)
+	if n >= 0 {
+		f.records.Remove(n)
+	}
+
+	return nil
+}
+
+func (f *fakeProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Proxied: true,
+		AutoTTL: true,
+		Types:   []string{"A", "AAAA", "CNAME", "MX", "TXT"},
+	}
+}
+
+// fakeConnector returns a connector serving provider for all zones.
+func fakeConnector(provider Provider) connector {
+	return func(zoneName string, opts options) (Provider, error) {
+		return provider, nil
+	}
+}
+
+func TestCapabilitiesCheck(t *testing.T) {
+	c := Capabilities{Types: []string{"A", "TXT"}}
+
+	cases := []struct {
+		in  Record
+		err string
+	}{
+		{Record{Name: "a", Type: "A", TTL: 300}, ""},
+		{Record{Name: "a", Type: "MX", TTL: 300}, "record type MX"},
+		{Record{Name: "a", Type: "A", TTL: cfCacheTTL, Proxied: true}, "proxied"},
+		{Record{Name: "a", Type: "A", TTL: cfAutoTTL}, "automatic TTL"},
+	}
+
+	for i, in := range cases {
+		err := c.Check(recordCollection{in.in})
+		if in.err == "" && err != nil {
+			t.Errorf("%d: Check() returned error: %s", i, err.Error())
+		}
+
+		if in.err != "" && (err == nil || !strings.Contains(err.Error(), in.err)) {
+			t.Errorf("%d: Check() returned wrong error %v, expected '%s'", i, err, in.err)
+		}
+	}
+}
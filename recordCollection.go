@@ -1,12 +1,12 @@
 package main
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
 	"strings"
 
-	"github.com/cloudflare/cloudflare-go"
 	"github.com/miekg/dns"
 )
 
@@ -14,11 +14,59 @@ const cfAutoTTL = 0  // This is the literal TTL in Cloudflare auto-TTL records
 const cfCacheTTL = 1 // This is the literal TTL in Cloudflare CDN records
 
 type (
-	recordCollection []cloudflare.DNSRecord
+	// Record is a DNS record independent of the provider hosting it.
+	Record struct {
+		// ID identifies the record at the provider. It's empty for records
+		// read from a zone file.
+		ID       string
+		Type     string
+		Name     string
+		Content  string
+		TTL      int
+		Priority int
+		Proxied  bool
+
+		// Comment and Tags are notes for humans from the zone file. They're
+		// shown in the diff output, but never compared.
+		Comment string
+		Tags    []string
+
+		// Source and Line are the file and line defining the record. They
+		// are empty for records from the provider, and the line is 0 if
+		// unknown.
+		Source string
+		Line   int
+	}
+
+	recordCollection []Record
+
+	// parsedZone is the result of parsing a zone file.
+	parsedZone struct {
+		Name    string
+		Records recordCollection
+
+		// Protected holds the records annotated as protected.
+		Protected recordCollection
+
+		// Skipped holds the records ignored by cfzone, like SOA and NS.
+		Skipped []dns.RR
+	}
+
+	// lineReader tracks the line where the record being parsed starts. The
+	// zone parser reads byte by byte when given an io.ByteReader, the
+	// position of the parser is known.
+	lineReader struct {
+		r *bufio.Reader
+
+		line      int
+		start     int
+		comment   bool
+		directive bool
+	}
 
 	// FilterFunc is used for finding records in a recordCollection. The
 	// function must return true if there is a hit, false otherwise.
-	FilterFunc func(a cloudflare.DNSRecord, b cloudflare.DNSRecord) bool
+	FilterFunc func(a Record, b Record) bool
 )
 
 // Clone will make a copy of a recordCollection.
@@ -36,7 +84,7 @@ func (c *recordCollection) Remove(n int) {
 }
 
 // Find will search for needle in a recordCollection.
-func (c recordCollection) Find(needle cloudflare.DNSRecord, match FilterFunc) (int, *cloudflare.DNSRecord) {
+func (c recordCollection) Find(needle Record, match FilterFunc) (int, *Record) {
 	for i, r := range c {
 		if match(r, needle) {
 			return i, &r
@@ -66,7 +114,8 @@ func (c recordCollection) Difference(remote recordCollection, match FilterFunc)
 
 // Intersect will find the intersection between c and remote [c ∩ remote] with
 // the caveat that the ID from c will be used in the result - while all other
-// properties will be copied from remote.
+// properties will be copied from remote. The source and line are kept from c
+// if remote has none.
 // If multiple record from a collection matches, only one will be present in
 // the returned collection.
 func (c recordCollection) Intersect(remote recordCollection, match FilterFunc) recordCollection {
@@ -83,6 +132,11 @@ func (c recordCollection) Intersect(remote recordCollection, match FilterFunc) r
 			record := *hit
 			record.ID = A[i].ID
 
+			if record.Source == "" && record.Line == 0 {
+				record.Source = A[i].Source
+				record.Line = A[i].Line
+			}
+
 			intersect = append(intersect, record)
 
 			// To make sure we're not double-spending we remove the found
@@ -99,7 +153,8 @@ func (c recordCollection) Intersect(remote recordCollection, match FilterFunc) r
 }
 
 // Fprint will output a textual representation of a recordCollection resembling
-// the BIND zone file format.
+// the BIND zone file format. The file and line defining each record are
+// added as a comment.
 func (c recordCollection) Fprint(w io.Writer) {
 	maxName := 0
 	for _, r := range c {
@@ -111,15 +166,114 @@ func (c recordCollection) Fprint(w io.Writer) {
 	for _, r := range c {
 		name := r.Name + "." + strings.Repeat(" ", maxName-len(r.Name))
 
-		proxied := ""
+		comments := []string{}
+		if isWildcard(r.Name) {
+			comments = append(comments, "WILDCARD")
+		}
+
 		if r.Proxied {
-			proxied = " ; PROXIED"
+			comments = append(comments, "PROXIED")
+		}
+
+		// Internationalized names are shown in both forms.
+		if u := unicodeName(r.Name); u != "" {
+			comments = append(comments, u)
+		}
+
+		if u := unicodeName(r.Content); u != "" && (r.Type == "CNAME" || r.Type == "MX") {
+			comments = append(comments, "target "+u)
+		}
+
+		if location := r.Location(); location != "" {
+			comments = append(comments, "from "+location)
+		}
+
+		if r.Comment != "" {
+			comments = append(comments, r.Comment)
+		}
+
+		if len(r.Tags) > 0 {
+			comments = append(comments, "tags: "+strings.Join(r.Tags, ","))
 		}
 
-		fmt.Fprintf(w, "%s %d %-8s %s%s\n", name, r.TTL, "IN "+r.Type, r.Content, proxied)
+		comment := ""
+		if len(comments) > 0 {
+			comment = " ; " + strings.Join(comments, ", ")
+		}
+
+		fmt.Fprintf(w, "%s %d %-8s %s%s\n", name, r.TTL, "IN "+r.Type, r.Content, comment)
 	}
 }
 
+// newLineReader will return a lineReader reading from r.
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{
+		r:    bufio.NewReader(r),
+		line: 1,
+	}
+}
+
+// Read implements io.Reader.
+func (l *lineReader) Read(p []byte) (int, error) {
+	return l.r.Read(p)
+}
+
+// ReadByte implements io.ByteReader.
+func (l *lineReader) ReadByte() (byte, error) {
+	b, err := l.r.ReadByte()
+	if err != nil {
+		return b, err
+	}
+
+	switch {
+	case b == '\n':
+		l.line++
+		l.comment = false
+
+		// Directives like $ORIGIN are not records.
+		if l.directive {
+			l.start = 0
+			l.directive = false
+		}
+
+	case l.start != 0 || l.comment:
+
+	case b == ';':
+		l.comment = true
+
+	case b == '$':
+		l.start = l.line
+		l.directive = true
+
+	case b != ' ' && b != '\t' && b != '\r':
+		l.start = l.line
+	}
+
+	return b, nil
+}
+
+// Next will return the line where the record just parsed starts.
+func (l *lineReader) Next() int {
+	line := l.start
+	l.start = 0
+
+	return line
+}
+
+// Location will return the file and line defining r as "file:line", or an
+// empty string for records from the provider.
+func (r Record) Location() string {
+	switch {
+	case r.Line == 0:
+		return r.Source
+
+	case r.Source == "":
+		return fmt.Sprintf("line %d", r.Line)
+	}
+
+	return fmt.Sprintf("%s:%d", r.Source, r.Line)
+}
+
 // parseZone will parse a BIND style zone file and return the zone name and
 // a recordCollection.
 func parseZone(r io.Reader) (string, recordCollection, error) {
@@ -130,46 +284,83 @@ func parseZoneWithOrigin(r io.Reader, origin string) (string, recordCollection,
 	return parseZoneWithOriginAndTTLs(r, origin, cfAutoTTL, cfCacheTTL)
 }
 func parseZoneWithOriginAndTTLs(r io.Reader, origin string, autoTTL, cacheTTL int) (string, recordCollection, error) {
-	var zoneName string
-	records := recordCollection{}
+	z, err := parseZoneFile(r, origin, autoTTL, cacheTTL)
+	if err != nil {
+		return "", recordCollection{}, err
+	}
 
-	p := dns.NewZoneParser(r, "", "")
+	return z.Name, z.Records, nil
+}
+
+// parseZoneFile will parse a BIND style zone file including the cfzone
+// annotations found in comments.
+func parseZoneFile(r io.Reader, origin string, autoTTL, cacheTTL int) (*parsedZone, error) {
+	z := &parsedZone{
+		Records:   recordCollection{},
+		Protected: recordCollection{},
+	}
+
+	if origin != "" {
+		origin = dns.Fqdn(origin)
+	}
+
+	lines := newLineReader(r)
+	p := dns.NewZoneParser(lines, origin, "")
 
 	for rr, ok := p.Next(); ok; rr, ok = p.Next() {
+		line := lines.Next()
+
 		// Search for zonename while we're at it.
 		soa, found := rr.(*dns.SOA)
 		if found {
-			zoneName = strings.Trim(soa.Header().Name, ".")
+			z.Name = strings.Trim(soa.Header().Name, ".")
 		}
 
 		r, err := newRecord(rr, autoTTL, cacheTTL)
 		if err != nil {
-			return "", recordCollection{}, err
+			return nil, err
+		}
+
+		if r == nil {
+			z.Skipped = append(z.Skipped, rr)
+
+			continue
 		}
 
-		if r != nil {
-			records = append(records, *r)
+		r.Comment = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(p.Comment()), ";"))
+		r.Line = line
+
+		z.Records = append(z.Records, *r)
+
+		if strings.Contains(r.Comment, protectAnnotation) {
+			z.Protected = append(z.Protected, *r)
 		}
 	}
 
 	if err := p.Err(); err != nil {
-		return "", recordCollection{}, err
+		return nil, err
+	}
+
+	// Fragments of a zone might not have a SOA record, the origin is used
+	// as the zone name instead.
+	if z.Name == "" {
+		z.Name = strings.Trim(origin, ".")
 	}
 
-	if zoneName == "" {
-		return "", recordCollection{}, errors.New("Zone name not found")
+	if z.Name == "" {
+		return nil, errors.New("Zone name not found")
 	}
 
-	return zoneName, records, nil
+	return z, nil
 }
 
-// newRecord will instantiate a new cloudflare-compatible DNS record based on
-// a token from miekg/dns..
+// newRecord will instantiate a new DNS record based on a token from
+// miekg/dns.
 // If the TTL has a value of 1 Proxied will be set to true in the resulting
 // DNSRecord mimicking Cloudflare internal TTL's.
 // A TTL of 0 will result in "automatic" TTL.
-func newRecord(in dns.RR, autoTTL, cacheTTL int) (*cloudflare.DNSRecord, error) {
-	record := &cloudflare.DNSRecord{
+func newRecord(in dns.RR, autoTTL, cacheTTL int) (*Record, error) {
+	record := &Record{
 		Name: strings.Trim(in.Header().Name, "."),
 		TTL:  int(in.Header().Ttl),
 	}
@@ -216,8 +407,11 @@ func newRecord(in dns.RR, autoTTL, cacheTTL int) (*cloudflare.DNSRecord, error)
 
 	case *dns.TXT:
 		record.Type = "TXT"
-		if len(v.Txt) > 0 {
-			record.Content = strings.Join(v.Txt, "")
+
+		// The strings are kept in the escaped presentation format by
+		// miekg/dns, the content is the raw value.
+		for _, txt := range v.Txt {
+			record.Content += unescapeTXT(txt)
 		}
 
 		return record, nil
@@ -245,7 +439,7 @@ func newRecord(in dns.RR, autoTTL, cacheTTL int) (*cloudflare.DNSRecord, error)
 
 // FullMatch will do matching between two DNS records while ignoring CF specific
 // details.
-func FullMatch(a cloudflare.DNSRecord, b cloudflare.DNSRecord) bool {
+func FullMatch(a Record, b Record) bool {
 	if a.Type != b.Type {
 		return false
 	}
@@ -279,7 +473,7 @@ func FullMatch(a cloudflare.DNSRecord, b cloudflare.DNSRecord) bool {
 
 // Updatable will return true if it makes sense to update (instead of
 // add/delete) from a to b or b to a.
-func Updatable(a cloudflare.DNSRecord, b cloudflare.DNSRecord) bool {
+func Updatable(a Record, b Record) bool {
 	if a.Type != b.Type {
 		return false
 	}
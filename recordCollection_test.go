@@ -6,8 +6,6 @@ import (
 	"reflect"
 	"strings"
 	"testing"
-
-	cloudflare "github.com/cloudflare/cloudflare-go"
 )
 
 func TestClone(t *testing.T) {
@@ -18,15 +16,15 @@ func TestClone(t *testing.T) {
 	}
 
 	a = recordCollection{
-		cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1"},
-		cloudflare.DNSRecord{Type: "A", Name: "a2", Content: "127.0.0.2"},
-		cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.10"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.11"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.12"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.13"},
-		cloudflare.DNSRecord{Type: "AAAA", Name: "a1", Content: "::1"},
-		cloudflare.DNSRecord{Type: "MX", Name: "@", Content: "mail", Priority: 10},
+		Record{Type: "A", Name: "a1", Content: "127.0.0.1"},
+		Record{Type: "A", Name: "a2", Content: "127.0.0.2"},
+		Record{Type: "A", Name: "a3", Content: "127.0.0.3"},
+		Record{Type: "A", Name: "a4", Content: "127.0.0.10"},
+		Record{Type: "A", Name: "a4", Content: "127.0.0.11"},
+		Record{Type: "A", Name: "a4", Content: "127.0.0.12"},
+		Record{Type: "A", Name: "a4", Content: "127.0.0.13"},
+		Record{Type: "AAAA", Name: "a1", Content: "::1"},
+		Record{Type: "MX", Name: "@", Content: "mail", Priority: 10},
 	}
 	b = a.Clone()
 	if !reflect.DeepEqual(a, b) {
@@ -36,18 +34,18 @@ func TestClone(t *testing.T) {
 
 func TestRemove(t *testing.T) {
 	in := recordCollection{
-		cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1", TTL: 100},
-		cloudflare.DNSRecord{Type: "A", Name: "a2", Content: "127.0.0.2", TTL: 200},
-		cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3", TTL: 300},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.4", TTL: 400},
+		Record{Type: "A", Name: "a1", Content: "127.0.0.1", TTL: 100},
+		Record{Type: "A", Name: "a2", Content: "127.0.0.2", TTL: 200},
+		Record{Type: "A", Name: "a3", Content: "127.0.0.3", TTL: 300},
+		Record{Type: "A", Name: "a4", Content: "127.0.0.4", TTL: 400},
 	}
 
 	a := in.Clone()
 	a.Remove(1)
 	if !reflect.DeepEqual(a, recordCollection{
-		cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1", TTL: 100},
-		cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3", TTL: 300},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.4", TTL: 400},
+		Record{Type: "A", Name: "a1", Content: "127.0.0.1", TTL: 100},
+		Record{Type: "A", Name: "a3", Content: "127.0.0.3", TTL: 300},
+		Record{Type: "A", Name: "a4", Content: "127.0.0.4", TTL: 400},
 	}) {
 		t.Errorf("Remove() did not return expected result")
 	}
@@ -55,9 +53,9 @@ func TestRemove(t *testing.T) {
 	a2 := in.Clone()
 	a2.Remove(1)
 	if !reflect.DeepEqual(a2, recordCollection{
-		cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1", TTL: 100},
-		cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3", TTL: 300},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.4", TTL: 400},
+		Record{Type: "A", Name: "a1", Content: "127.0.0.1", TTL: 100},
+		Record{Type: "A", Name: "a3", Content: "127.0.0.3", TTL: 300},
+		Record{Type: "A", Name: "a4", Content: "127.0.0.4", TTL: 400},
 	}) {
 		t.Errorf("Remove() did not return expected result")
 	}
@@ -66,7 +64,7 @@ func TestRemove(t *testing.T) {
 func TestFindEmpty(t *testing.T) {
 	c := recordCollection{}
 
-	n, r := c.Find(cloudflare.DNSRecord{}, FullMatch)
+	n, r := c.Find(Record{}, FullMatch)
 	if n >= 0 {
 		t.Errorf("Find() returned a non-negative value from an empty collection")
 	}
@@ -78,18 +76,18 @@ func TestFindEmpty(t *testing.T) {
 
 func TestFullMatch(t *testing.T) {
 	cases := []struct {
-		a        cloudflare.DNSRecord
-		b        cloudflare.DNSRecord
+		a        Record
+		b        Record
 		expected bool
 	}{
-		{cloudflare.DNSRecord{Type: "A"}, cloudflare.DNSRecord{Type: "A"}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a"}, cloudflare.DNSRecord{Type: "A", Name: "a"}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a"}, cloudflare.DNSRecord{Type: "A", Name: "ab"}, false},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 1}, false},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: true}, cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: true}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: true}, cloudflare.DNSRecord{Type: "A", Name: "a"}, false},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 3600}, false},
+		{Record{Type: "A"}, Record{Type: "A"}, true},
+		{Record{Type: "A", Name: "a"}, Record{Type: "A", Name: "a"}, true},
+		{Record{Type: "A", Name: "a"}, Record{Type: "A", Name: "ab"}, false},
+		{Record{Type: "A", Name: "a", TTL: 0}, Record{Type: "A", Name: "a", TTL: 0}, true},
+		{Record{Type: "A", Name: "a", TTL: 0}, Record{Type: "A", Name: "a", TTL: 1}, false},
+		{Record{Type: "A", Name: "a", Proxied: true}, Record{Type: "A", Name: "a", Proxied: true}, true},
+		{Record{Type: "A", Name: "a", Proxied: true}, Record{Type: "A", Name: "a"}, false},
+		{Record{Type: "A", Name: "a", TTL: 0}, Record{Type: "A", Name: "a", TTL: 3600}, false},
 	}
 
 	for i, in := range cases {
@@ -103,19 +101,19 @@ func TestFullMatch(t *testing.T) {
 
 func TestUpdatable(t *testing.T) {
 	cases := []struct {
-		a        cloudflare.DNSRecord
-		b        cloudflare.DNSRecord
+		a        Record
+		b        Record
 		expected bool
 	}{
-		{cloudflare.DNSRecord{Type: "A"}, cloudflare.DNSRecord{Type: "A"}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a"}, cloudflare.DNSRecord{Type: "A", Name: "a"}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a"}, cloudflare.DNSRecord{Type: "A", Name: "ab"}, false},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 1}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: true}, cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: true}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", Proxied: true}, cloudflare.DNSRecord{Type: "A", Name: "a"}, true},
-		{cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 3600}, true},
-		{cloudflare.DNSRecord{Type: "CNAME", Name: "a", TTL: 0}, cloudflare.DNSRecord{Type: "A", Name: "a", TTL: 3600}, false},
+		{Record{Type: "A"}, Record{Type: "A"}, true},
+		{Record{Type: "A", Name: "a"}, Record{Type: "A", Name: "a"}, true},
+		{Record{Type: "A", Name: "a"}, Record{Type: "A", Name: "ab"}, false},
+		{Record{Type: "A", Name: "a", TTL: 0}, Record{Type: "A", Name: "a", TTL: 0}, true},
+		{Record{Type: "A", Name: "a", TTL: 0}, Record{Type: "A", Name: "a", TTL: 1}, true},
+		{Record{Type: "A", Name: "a", Proxied: true}, Record{Type: "A", Name: "a", Proxied: true}, true},
+		{Record{Type: "A", Name: "a", Proxied: true}, Record{Type: "A", Name: "a"}, true},
+		{Record{Type: "A", Name: "a", TTL: 0}, Record{Type: "A", Name: "a", TTL: 3600}, true},
+		{Record{Type: "CNAME", Name: "a", TTL: 0}, Record{Type: "A", Name: "a", TTL: 3600}, false},
 	}
 
 	for i, in := range cases {
@@ -129,31 +127,31 @@ func TestUpdatable(t *testing.T) {
 
 func TestFind(t *testing.T) {
 	c := recordCollection{
-		cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1"},
-		cloudflare.DNSRecord{Type: "A", Name: "a2", Content: "127.0.0.2"},
-		cloudflare.DNSRecord{Type: "A", Name: "a3", Content: "127.0.0.3"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.10"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.11"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.12"},
-		cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.13"},
-		cloudflare.DNSRecord{Type: "AAAA", Name: "a1", Content: "::1"},
-		cloudflare.DNSRecord{Type: "MX", Name: "@", Content: "mail", Priority: 10},
+		Record{Type: "A", Name: "a1", Content: "127.0.0.1"},
+		Record{Type: "A", Name: "a2", Content: "127.0.0.2"},
+		Record{Type: "A", Name: "a3", Content: "127.0.0.3"},
+		Record{Type: "A", Name: "a4", Content: "127.0.0.10"},
+		Record{Type: "A", Name: "a4", Content: "127.0.0.11"},
+		Record{Type: "A", Name: "a4", Content: "127.0.0.12"},
+		Record{Type: "A", Name: "a4", Content: "127.0.0.13"},
+		Record{Type: "AAAA", Name: "a1", Content: "::1"},
+		Record{Type: "MX", Name: "@", Content: "mail", Priority: 10},
 	}
 
 	cases := []struct {
-		needle cloudflare.DNSRecord
+		needle Record
 		n      int
-		r      *cloudflare.DNSRecord
+		r      *Record
 	}{
-		{cloudflare.DNSRecord{Type: "A", Name: "a1"}, -1, nil},
-		{cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.2"}, -1, nil},
-		{cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1"}, 0, &cloudflare.DNSRecord{}},
-		{cloudflare.DNSRecord{Type: "A", Name: "a1", Content: "127.0.0.1"}, 0, &cloudflare.DNSRecord{}},
-		{cloudflare.DNSRecord{Type: "A", Name: "a4", Content: "127.0.0.12"}, 5, &cloudflare.DNSRecord{}},
-		{cloudflare.DNSRecord{Type: "MX", Name: "a1", Content: "127.0.0.12"}, -1, nil},
-		{cloudflare.DNSRecord{Type: "MX", Name: "@", Content: "127.0.0.12"}, -1, nil},
-		{cloudflare.DNSRecord{Type: "MX", Name: "@", Content: "::1"}, -1, nil},
-		{cloudflare.DNSRecord{Type: "MX", Name: "@", Content: "mail", Priority: 10}, 8, &cloudflare.DNSRecord{}},
+		{Record{Type: "A", Name: "a1"}, -1, nil},
+		{Record{Type: "A", Name: "a1", Content: "127.0.0.2"}, -1, nil},
+		{Record{Type: "A", Name: "a1", Content: "127.0.0.1"}, 0, &Record{}},
+		{Record{Type: "A", Name: "a1", Content: "127.0.0.1"}, 0, &Record{}},
+		{Record{Type: "A", Name: "a4", Content: "127.0.0.12"}, 5, &Record{}},
+		{Record{Type: "MX", Name: "a1", Content: "127.0.0.12"}, -1, nil},
+		{Record{Type: "MX", Name: "@", Content: "127.0.0.12"}, -1, nil},
+		{Record{Type: "MX", Name: "@", Content: "::1"}, -1, nil},
+		{Record{Type: "MX", Name: "@", Content: "mail", Priority: 10}, 8, &Record{}},
 	}
 
 	for i, in := range cases {
@@ -170,9 +168,9 @@ func TestFind(t *testing.T) {
 
 func TestDifference(t *testing.T) {
 	empty := recordCollection{}
-	a1 := cloudflare.DNSRecord{Type: "A", Name: "test1", Content: "127.0.0.1"}
-	a2 := cloudflare.DNSRecord{Type: "A", Name: "test1", Content: "127.0.0.2"}
-	aaaa1 := cloudflare.DNSRecord{Type: "AAAA", Name: "test1", Content: "::1"}
+	a1 := Record{Type: "A", Name: "test1", Content: "127.0.0.1"}
+	a2 := Record{Type: "A", Name: "test1", Content: "127.0.0.2"}
+	aaaa1 := Record{Type: "AAAA", Name: "test1", Content: "::1"}
 	cases := []struct {
 		a        recordCollection
 		b        recordCollection
@@ -198,8 +196,8 @@ func TestDifference(t *testing.T) {
 
 func TestIntersect(t *testing.T) {
 	empty := recordCollection{}
-	a1 := cloudflare.DNSRecord{Type: "A", Name: "test1", Content: "127.0.0.1"}
-	aaaa1 := cloudflare.DNSRecord{Type: "AAAA", Name: "test1", Content: "::1"}
+	a1 := Record{Type: "A", Name: "test1", Content: "127.0.0.1"}
+	aaaa1 := Record{Type: "AAAA", Name: "test1", Content: "::1"}
 	cases := []struct {
 		a        recordCollection
 		b        recordCollection
@@ -222,9 +220,9 @@ func TestIntersect(t *testing.T) {
 
 func TestFprint(t *testing.T) {
 	c := recordCollection{
-		cloudflare.DNSRecord{Name: "a1", TTL: 0, Type: "A", Content: "127.0.0.1"},
-		cloudflare.DNSRecord{Name: "a2", TTL: 1, Type: "A", Content: "127.0.0.2", Proxied: true},
-		cloudflare.DNSRecord{Name: "aaaa1", TTL: 0, Type: "AAAA", Content: "::1"},
+		Record{Name: "a1", TTL: 0, Type: "A", Content: "127.0.0.1"},
+		Record{Name: "a2", TTL: 1, Type: "A", Content: "127.0.0.2", Proxied: true},
+		Record{Name: "aaaa1", TTL: 0, Type: "AAAA", Content: "::1"},
 	}
 	expected := `a1.    0 IN A     127.0.0.1
 a2.    1 IN A     127.0.0.2 ; PROXIED
@@ -242,6 +240,47 @@ aaaa1. 0 IN AAAA  ::1
 	}
 }
 
+func TestFprintLocations(t *testing.T) {
+	c := recordCollection{
+		Record{Name: "mail", TTL: 0, Type: "MX", Content: "mx.example.com", Source: "mail.zone", Line: 3},
+		Record{Name: "www", TTL: 1, Type: "A", Content: "127.0.0.2", Proxied: true, Source: "web.yaml"},
+		Record{Name: "ftp", TTL: 0, Type: "A", Content: "127.0.0.4", Line: 7, Comment: "old"},
+		Record{Name: "old", TTL: 0, Type: "A", Content: "127.0.0.3"},
+	}
+	expected := `mail. 0 IN MX    mx.example.com ; from mail.zone:3
+www.  1 IN A     127.0.0.2 ; PROXIED, from web.yaml
+ftp.  0 IN A     127.0.0.4 ; from line 7, old
+old.  0 IN A     127.0.0.3
+`
+
+	var b bytes.Buffer
+	c.Fprint(&b)
+
+	if b.String() != expected {
+		t.Fatalf("Fprint() returned wrong output, got [%s], expected [%s]", b.String(), expected)
+	}
+}
+
+func TestLocationCarried(t *testing.T) {
+	remote := recordCollection{Record{ID: "1", Type: "A", Name: "www", Content: "127.0.0.1"}}
+	file := recordCollection{Record{Type: "A", Name: "www", Content: "127.0.0.2", Source: "web.zone", Line: 4}}
+
+	result := file.Difference(remote, FullMatch)
+	if len(result) != 1 || result[0].Location() != "web.zone:4" {
+		t.Errorf("Difference() lost the location, got %+v", result)
+	}
+
+	result = remote.Intersect(file, Updatable)
+	if len(result) != 1 || result[0].Location() != "web.zone:4" || result[0].ID != "1" {
+		t.Errorf("Intersect() lost the location, got %+v", result)
+	}
+
+	result = file.Intersect(remote, Updatable)
+	if len(result) != 1 || result[0].Location() != "web.zone:4" || result[0].Content != "127.0.0.1" {
+		t.Errorf("Intersect() lost the location, got %+v", result)
+	}
+}
+
 func TestParseZone(t *testing.T) {
 	zone := `
 $ORIGIN example.com.
@@ -267,43 +306,49 @@ test4 1 IN A 127.0.0.4
 `
 
 	parsed := recordCollection{
-		cloudflare.DNSRecord{
+		Record{
 			Type:     "MX",
 			Priority: 10,
 			Name:     "example.com",
 			Content:  "mail10.example.com",
 			TTL:      1800,
+			Line:     16,
 		},
-		cloudflare.DNSRecord{
+		Record{
 			Type:    "A",
 			Name:    "test1.example.com",
 			Content: "127.0.0.1",
 			TTL:     1800,
+			Line:    17,
 		},
-		cloudflare.DNSRecord{
+		Record{
 			Type:    "CNAME",
 			Name:    "test2.example.com",
 			Content: "test1.example.com",
 			TTL:     1800,
+			Line:    18,
 		},
-		cloudflare.DNSRecord{
+		Record{
 			Type:    "AAAA",
 			Name:    "test3.example.com",
 			Content: "::1",
 			TTL:     1800,
+			Line:    19,
 		},
-		cloudflare.DNSRecord{
+		Record{
 			Type:    "A",
 			Name:    "test4.example.com",
 			Content: "127.0.0.4",
 			TTL:     1,
 			Proxied: true,
+			Line:    20,
 		},
-		cloudflare.DNSRecord{
+		Record{
 			Type:    "TXT",
 			Name:    "example.com",
 			Content: "v=spf1 include:spf.example.com -all",
 			TTL:     1800,
+			Line:    21,
 		},
 	}
 
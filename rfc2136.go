@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// cloudflareProviderName and rfc2136ProviderName are the values
+	// accepted by -provider.
+	cloudflareProviderName = "cloudflare"
+	rfc2136ProviderName    = "rfc2136"
+)
+
+type (
+	// rfc2136Provider is a Provider for a zone hosted by a DNS server
+	// accepting RFC 2136 dynamic updates. The records are read by zone
+	// transfer (AXFR).
+	rfc2136Provider struct {
+		server string
+		zone   string
+		key    tsigKey
+	}
+)
+
+// newRFC2136Connector will return a connector for zones hosted by the DNS
+// server at server. The port defaults to 53.
+func newRFC2136Connector(server string, key tsigKey) (connector, error) {
+	if server == "" {
+		return nil, errors.New("Please specify the DNS server using -server")
+	}
+
+	server = withDefaultPort(server)
+
+	key, err := newTSIGKey(key.Name, key.Algorithm, key.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(zoneName string, opts options) (Provider, error) {
+		return &rfc2136Provider{
+			server: server,
+			zone:   dns.Fqdn(zoneName),
+			key:    key,
+		}, nil
+	}, nil
+}
+
+// Records implements Provider.
+func (p *rfc2136Provider) Records(filter Record) (recordCollection, error) {
+	rrs, err := transferZone(p.server, p.zone, p.key)
+	if err != nil {
+		return nil, err
+	}
+
+	result := recordCollection{}
+	for _, rr := range rrs {
+		// Proxying and automatic TTLs are Cloudflare features, TTLs are
+		// used as is.
+		r, err := newRecord(rr, -1, -1)
+		if err != nil {
+			// Records of types not supported by cfzone are left alone.
+			continue
+		}
+
+		if r == nil || !filterMatch(*r, filter) {
+			continue
+		}
+
+		// The ID is the record itself, the update messages need the old
+		// record for removing it.
+		r.ID = rr.String()
+
+		result = append(result, *r)
+	}
+
+	return result, nil
+}
+
+// update will send an update message built by build to the server.
+func (p *rfc2136Provider) update(build func(m *dns.Msg)) error {
+	m := new(dns.Msg)
+	m.SetUpdate(p.zone)
+	build(m)
+
+	c := &dns.Client{
+		Net:        "tcp",
+		TsigSecret: p.key.sign(m),
+	}
+
+	response, _, err := c.Exchange(m, p.server)
+	if err != nil {
+		return err
+	}
+
+	if response.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("update refused by %s: %s", p.server, dns.RcodeToString[response.Rcode])
+	}
+
+	return nil
+}
+
+// Create implements Provider.
+func (p *rfc2136Provider) Create(r Record) (Record, error) {
+	rr, err := toRR(r)
+	if err != nil {
+		return Record{}, err
+	}
+
+	err = p.update(func(m *dns.Msg) {
+		m.Insert([]dns.RR{rr})
+	})
+	if err != nil {
+		return Record{}, err
+	}
+
+	r.ID = rr.String()
+
+	return r, nil
+}
+
+// Update implements Provider.
+func (p *rfc2136Provider) Update(r Record) error {
+	old, err := dns.NewRR(r.ID)
+	if err != nil {
+		return fmt.Errorf("invalid record ID '%s': %s", r.ID, err.Error())
+	}
+
+	rr, err := toRR(r)
+	if err != nil {
+		return err
+	}
+
+	// Both changes are made in a single message, the server applies them
+	// atomically.
+	return p.update(func(m *dns.Msg) {
+		m.Remove([]dns.RR{old})
+		m.Insert([]dns.RR{rr})
+	})
+}
+
+// Delete implements Provider.
+func (p *rfc2136Provider) Delete(r Record) error {
+	rr, err := dns.NewRR(r.ID)
+	if err != nil || rr == nil {
+		rr, err = toRR(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	return p.update(func(m *dns.Msg) {
+		m.Remove([]dns.RR{rr})
+	})
+}
+
+// Capabilities implements Provider.
+func (p *rfc2136Provider) Capabilities() Capabilities {
+	return Capabilities{
+		Types: []string{"A", "AAAA", "CNAME", "MX", "TXT"},
+	}
+}
+
+// toRR will convert r to a miekg/dns resource record.
+func toRR(r Record) (dns.RR, error) {
+	hdr := dns.RR_Header{
+		Name:  dns.Fqdn(r.Name),
+		Class: dns.ClassINET,
+		Ttl:   uint32(r.TTL),
+	}
+
+	switch r.Type {
+	case "A":
+		ip := net.ParseIP(r.Content).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IPv4 address '%s'", r.Content)
+		}
+
+		hdr.Rrtype = dns.TypeA
+
+		return &dns.A{Hdr: hdr, A: ip}, nil
+
+	case "AAAA":
+		ip := net.ParseIP(r.Content)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IPv6 address '%s'", r.Content)
+		}
+
+		hdr.Rrtype = dns.TypeAAAA
+
+		return &dns.AAAA{Hdr: hdr, AAAA: ip}, nil
+
+	case "CNAME":
+		hdr.Rrtype = dns.TypeCNAME
+
+		return &dns.CNAME{Hdr: hdr, Target: dns.Fqdn(r.Content)}, nil
+
+	case "MX":
+		hdr.Rrtype = dns.TypeMX
+
+		return &dns.MX{Hdr: hdr, Preference: uint16(r.Priority), Mx: dns.Fqdn(r.Content)}, nil
+
+	case "TXT":
+		hdr.Rrtype = dns.TypeTXT
+
+		txt := []string{}
+		for _, chunk := range splitTXT(r.Content) {
+			txt = append(txt, escapeTXT(chunk))
+		}
+
+		return &dns.TXT{Hdr: hdr, Txt: txt}, nil
+	}
+
+	return nil, fmt.Errorf("record type %s is not supported", r.Type)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	testTsigName   = "cfzone."
+	testTsigSecret = "c2VjcmV0IHNlY3JldCBzZWNyZXQ="
+)
+
+// testDNSServer is a minimal authoritative server accepting AXFR and RFC
+// 2136 updates signed with TSIG.
+type testDNSServer struct {
+	sync.Mutex
+
+	zone    string
+	records []dns.RR
+	updates int
+}
+
+func (s *testDNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	s.Lock()
+	defer s.Unlock()
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+
+	if r.IsTsig() == nil || w.TsigStatus() != nil {
+		m.SetRcode(r, dns.RcodeNotAuth)
+		w.WriteMsg(m)
+
+		return
+	}
+
+	switch r.Opcode {
+	case dns.OpcodeQuery:
+		soa, _ := dns.NewRR(s.zone + " 3600 IN SOA ns1." + s.zone + " hostmaster." + s.zone + " 1 3600 600 86400 300")
+
+		m.Answer = append([]dns.RR{soa}, s.records...)
+		m.Answer = append(m.Answer, soa)
+
+	case dns.OpcodeUpdate:
+		s.updates++
+
+		for _, rr := range r.Ns {
+			switch rr.Header().Class {
+			case dns.ClassNONE:
+				for i, existing := range s.records {
+					rr.Header().Class = dns.ClassINET
+					rr.Header().Ttl = existing.Header().Ttl
+					if dns.IsDuplicate(existing, rr) {
+						s.records = append(s.records[:i], s.records[i+1:]...)
+
+						break
+					}
+				}
+
+			case dns.ClassINET:
+				s.records = append(s.records, rr)
+			}
+		}
+	}
+
+	m.SetTsig(testTsigName, dns.HmacSHA256, tsigFudge, int64(r.IsTsig().TimeSigned))
+	w.WriteMsg(m)
+}
+
+func startTestDNSServer(t *testing.T, zone string, records ...string) (*testDNSServer, string, func()) {
+	handler := &testDNSServer{zone: zone}
+	for _, s := range records {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatalf("NewRR() failed: %s", err.Error())
+		}
+
+		handler.records = append(handler.records, rr)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %s", err.Error())
+	}
+
+	started := make(chan struct{})
+	server := &dns.Server{
+		Listener:          l,
+		Handler:           handler,
+		TsigSecret:        map[string]string{testTsigName: testTsigSecret},
+		NotifyStartedFunc: func() { close(started) },
+
+		// The default rejects updates.
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+	}
+
+	go server.ActivateAndServe()
+	<-started
+
+	return handler, l.Addr().String(), func() { server.Shutdown() }
+}
+
+func TestRFC2136Provider(t *testing.T) {
+	handler, addr, stop := startTestDNSServer(t, "example.com.",
+		"www.example.com. 300 IN A 127.0.0.1",
+		"example.com. 300 IN MX 10 mx.example.com.",
+		"example.com. 300 IN NS ns1.example.com.",
+	)
+	defer stop()
+
+	connect, err := newRFC2136Connector(addr, tsigKey{Name: "cfzone", Algorithm: dns.HmacSHA256, Secret: testTsigSecret})
+	if err != nil {
+		t.Fatalf("newRFC2136Connector() returned error: %s", err.Error())
+	}
+
+	p, err := connect("example.com", options{})
+	if err != nil {
+		t.Fatalf("connector returned error: %s", err.Error())
+	}
+
+	records, err := p.Records(Record{})
+	if err != nil {
+		t.Fatalf("Records() returned error: %s", err.Error())
+	}
+
+	if len(records) != 2 || records[0].Name != "www.example.com" || records[1].Priority != 10 {
+		t.Fatalf("Records() returned wrong records: %+v", records)
+	}
+
+	www := records[0]
+	www.Content = "127.0.0.2"
+
+	err = p.Update(www)
+	if err != nil {
+		t.Fatalf("Update() returned error: %s", err.Error())
+	}
+
+	_, err = p.Create(Record{Type: "TXT", Name: "example.com", Content: strings.Repeat("x", 300), TTL: 300})
+	if err != nil {
+		t.Fatalf("Create() returned error: %s", err.Error())
+	}
+
+	err = p.Delete(records[1])
+	if err != nil {
+		t.Fatalf("Delete() returned error: %s", err.Error())
+	}
+
+	records, err = p.Records(Record{})
+	if err != nil {
+		t.Fatalf("Records() returned error: %s", err.Error())
+	}
+
+	expected := recordCollection{
+		{Type: "A", Name: "www.example.com", Content: "127.0.0.2", TTL: 300},
+		{Type: "TXT", Name: "example.com", Content: strings.Repeat("x", 300), TTL: 300},
+	}
+
+	if len(records.Difference(expected, FullMatch)) != 0 || len(expected.Difference(records, FullMatch)) != 0 {
+		t.Errorf("provider did not apply changes, got %+v", records)
+	}
+
+	handler.Lock()
+	defer handler.Unlock()
+
+	if handler.updates != 3 {
+		t.Errorf("provider sent %d updates, expected 3", handler.updates)
+	}
+}
+
+func TestRFC2136ProviderUnsigned(t *testing.T) {
+	_, addr, stop := startTestDNSServer(t, "example.com.")
+	defer stop()
+
+	connect, err := newRFC2136Connector(addr, tsigKey{})
+	if err != nil {
+		t.Fatalf("newRFC2136Connector() returned error: %s", err.Error())
+	}
+
+	p, _ := connect("example.com", options{})
+
+	_, err = p.Create(Record{Type: "A", Name: "www.example.com", Content: "127.0.0.1", TTL: 300})
+	if err == nil || !strings.Contains(err.Error(), "NOTAUTH") {
+		t.Errorf("Create() did not fail on unsigned update, got %v", err)
+	}
+}
+
+func TestNewRFC2136Connector(t *testing.T) {
+	_, err := newRFC2136Connector("", tsigKey{})
+	if err == nil {
+		t.Errorf("newRFC2136Connector() failed to err on missing server")
+	}
+
+	_, err = newRFC2136Connector("ns1.example.com", tsigKey{Name: "cfzone"})
+	if err == nil {
+		t.Errorf("newRFC2136Connector() failed to err on missing secret")
+	}
+
+	connect, err := newRFC2136Connector("ns1.example.com", tsigKey{})
+	if err != nil {
+		t.Fatalf("newRFC2136Connector() returned error: %s", err.Error())
+	}
+
+	p, _ := connect("example.com", options{})
+	if p.(*rfc2136Provider).server != "ns1.example.com:53" {
+		t.Errorf("newRFC2136Connector() did not add the default port, got %s", p.(*rfc2136Provider).server)
+	}
+}
+
+func TestToRR(t *testing.T) {
+	cases := []struct {
+		in       Record
+		expected string
+	}{
+		{Record{Type: "A", Name: "example.com", Content: "127.0.0.1", TTL: 300}, "example.com.\t300\tIN\tA\t127.0.0.1"},
+		{Record{Type: "AAAA", Name: "example.com", Content: "::1", TTL: 300}, "example.com.\t300\tIN\tAAAA\t::1"},
+		{Record{Type: "CNAME", Name: "www.example.com", Content: "example.com", TTL: 300}, "www.example.com.\t300\tIN\tCNAME\texample.com."},
+		{Record{Type: "MX", Name: "example.com", Content: "mx.example.com", TTL: 300, Priority: 10}, "example.com.\t300\tIN\tMX\t10 mx.example.com."},
+		{Record{Type: "TXT", Name: "example.com", Content: "hello", TTL: 300}, "example.com.\t300\tIN\tTXT\t\"hello\""},
+		{Record{Type: "A", Name: "example.com", Content: "::1", TTL: 300}, ""},
+		{Record{Type: "SRV", Name: "example.com", Content: "", TTL: 300}, ""},
+	}
+
+	for i, in := range cases {
+		rr, err := toRR(in.in)
+		if in.expected == "" {
+			if err == nil {
+				t.Errorf("%d: toRR() failed to err on %+v", i, in.in)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%d: toRR() returned error: %s", i, err.Error())
+
+			continue
+		}
+
+		if rr.String() != in.expected {
+			t.Errorf("%d: toRR() returned '%s', expected '%s'", i, rr.String(), in.expected)
+		}
+	}
+}
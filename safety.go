@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+)
+
+// checkThresholds will return an error if the number of destructive changes
+// exceeds the limits given. deletes and updates are the number of records to
+// be deleted and updated, existing the number of records present at
+// Cloudflare before syncing. A negative limit disables the check.
+func checkThresholds(deletes int, updates int, existing int, maxDeletes int, maxPercent float64) error {
+	if maxDeletes >= 0 && deletes > maxDeletes {
+		return fmt.Errorf("%d records would be deleted, the limit is %d", deletes, maxDeletes)
+	}
+
+	if maxPercent >= 0 && existing > 0 {
+		percent := float64(deletes+updates) * 100 / float64(existing)
+		if percent > maxPercent {
+			return fmt.Errorf("%.1f%% of the existing records would be deleted or updated, the limit is %.1f%%", percent, maxPercent)
+		}
+	}
+
+	return nil
+}
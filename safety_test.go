@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCheckThresholds(t *testing.T) {
+	cases := []struct {
+		deletes    int
+		updates    int
+		existing   int
+		maxDeletes int
+		maxPercent float64
+		err        bool
+	}{
+		{0, 0, 0, -1, -1, false},
+		{100, 100, 100, -1, -1, false},
+		{5, 0, 100, 5, -1, false},
+		{6, 0, 100, 5, -1, true},
+		{1, 0, 100, 0, -1, true},
+		{5, 5, 100, -1, 10, false},
+		{5, 6, 100, -1, 10, true},
+		{0, 0, 0, -1, 10, false},
+		{0, 1, 0, -1, 10, false},
+		{1, 0, 1, -1, 0, true},
+	}
+
+	for i, in := range cases {
+		err := checkThresholds(in.deletes, in.updates, in.existing, in.maxDeletes, in.maxPercent)
+		if in.err && err == nil {
+			t.Errorf("%d: checkThresholds() failed to err on %+v", i, in)
+		}
+
+		if !in.err && err != nil {
+			t.Errorf("%d: checkThresholds() returned error on %+v: %s", i, in, err.Error())
+		}
+	}
+}
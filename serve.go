@@ -0,0 +1,563 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// errUnchanged is returned by sync when the zone is unchanged.
+var errUnchanged = errors.New("zone unchanged")
+
+var (
+	// Settings for "cfzone serve".
+	listenAddr      = ":53"
+	httpAddr        = ":8053"
+	debounce        = 5 * time.Second
+	refreshInterval = time.Hour
+
+	// allowNotify holds the networks NOTIFY messages are accepted from, in
+	// addition to the primary servers of the zones.
+	allowNotify = networkList{}
+)
+
+type (
+	// networkList is a list of networks given as addresses or CIDRs.
+	networkList []*net.IPNet
+
+	// watchedZone is a zone kept in sync with a primary server.
+	watchedZone struct {
+		URL    string
+		Name   string
+		server string
+
+		timer *time.Timer
+
+		// These are protected by the mutex of the secondary.
+		Serial    uint32
+		Synced    bool
+		LastCheck time.Time
+		LastSync  time.Time
+		LastError string
+		Changes   int
+	}
+
+	// secondary keeps zones in sync with their primary servers, driven by
+	// NOTIFY messages.
+	secondary struct {
+		connect     connector
+		key         tsigKey
+		debounce    time.Duration
+		allowNotify networkList
+
+		// zones is indexed by the canonical zone name.
+		zones map[string]*watchedZone
+
+		// syncMu serializes syncs, the settings are global. stateMu
+		// protects the state of the zones.
+		syncMu  sync.Mutex
+		stateMu sync.Mutex
+
+		// pending counts the scheduled and running refreshes. No refreshes
+		// are scheduled once stopped is set.
+		pending sync.WaitGroup
+		stopped bool
+	}
+
+	// zoneStatus is the status of a zone as reported by /status.
+	zoneStatus struct {
+		Zone      string    `json:"zone"`
+		Source    string    `json:"source"`
+		Serial    uint32    `json:"serial"`
+		Synced    bool      `json:"synced"`
+		LastCheck time.Time `json:"last_check"`
+		LastSync  time.Time `json:"last_sync"`
+		LastError string    `json:"last_error,omitempty"`
+		Changes   int       `json:"changes"`
+	}
+)
+
+// String implements flag.Value.
+func (n *networkList) String() string {
+	networks := []string{}
+	for _, network := range *n {
+		networks = append(networks, network.String())
+	}
+
+	return strings.Join(networks, ",")
+}
+
+// Set implements flag.Value. An address is a network of that address only.
+func (n *networkList) Set(value string) error {
+	if ip := net.ParseIP(value); ip != nil {
+		bits := 8 * len(ip.To16())
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+
+		*n = append(*n, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+
+		return nil
+	}
+
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return fmt.Errorf("'%s' is not an address or a network", value)
+	}
+
+	*n = append(*n, network)
+
+	return nil
+}
+
+// Contains will return true if ip is in one of the networks.
+func (n networkList) Contains(ip net.IP) bool {
+	for _, network := range n {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newSecondary will return a secondary for the zones given as axfr:// URLs.
+// NOTIFY messages are accepted from the primary servers and allowNotify.
+func newSecondary(connect connector, key tsigKey, debounce time.Duration, allowNotify networkList, urls []string) (*secondary, error) {
+	s := &secondary{
+		connect:     connect,
+		key:         key,
+		debounce:    debounce,
+		allowNotify: allowNotify,
+		zones:       map[string]*watchedZone{},
+	}
+
+	for _, u := range urls {
+		server, name, err := parseAXFRURL(u)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid zone URL '%s': %s", u, err.Error())
+		}
+
+		fqdn := dns.Fqdn(strings.ToLower(name))
+		if _, found := s.zones[fqdn]; found {
+			return nil, fmt.Errorf("Zone '%s' is given more than once", name)
+		}
+
+		s.zones[fqdn] = &watchedZone{
+			URL:    u,
+			Name:   strings.Trim(name, "."),
+			server: server,
+		}
+	}
+
+	return s, nil
+}
+
+// checkNotify will return an error if the NOTIFY message r for z must be
+// refused. It must come from the primary server or an allowed network, and
+// be signed if a TSIG key is set.
+func (s *secondary) checkNotify(w dns.ResponseWriter, r *dns.Msg, z *watchedZone) error {
+	if s.key.Name != "" {
+		tsig := r.IsTsig()
+		if tsig == nil {
+			return errors.New("not signed")
+		}
+
+		if !strings.EqualFold(tsig.Hdr.Name, s.key.Name) {
+			return fmt.Errorf("signed with unknown key '%s'", tsig.Hdr.Name)
+		}
+
+		err := w.TsigStatus()
+		if err != nil {
+			return fmt.Errorf("bad signature: %s", err.Error())
+		}
+	}
+
+	var ip net.IP
+	switch addr := w.RemoteAddr().(type) {
+	case *net.UDPAddr:
+		ip = addr.IP
+
+	case *net.TCPAddr:
+		ip = addr.IP
+	}
+
+	if s.allowNotify.Contains(ip) {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(z.server)
+	if err != nil {
+		host = z.server
+	}
+
+	primaries := []net.IP{net.ParseIP(host)}
+	if primaries[0] == nil {
+		primaries, _ = net.LookupIP(host)
+	}
+
+	for _, primary := range primaries {
+		if primary.Equal(ip) {
+			return nil
+		}
+	}
+
+	return errors.New("not from the primary server or -allow-notify")
+}
+
+// ServeDNS implements dns.Handler. NOTIFY messages for known zones will
+// schedule a sync of the zone, everything else is refused.
+func (s *secondary) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+
+	if r.Opcode != dns.OpcodeNotify || len(r.Question) != 1 {
+		m.SetRcode(r, dns.RcodeRefused)
+		w.WriteMsg(m)
+
+		return
+	}
+
+	z, found := s.zones[strings.ToLower(r.Question[0].Name)]
+	if !found {
+		m.SetRcode(r, dns.RcodeNotAuth)
+		w.WriteMsg(m)
+
+		return
+	}
+
+	err := s.checkNotify(w, r, z)
+	if err != nil {
+		m.SetRcode(r, dns.RcodeRefused)
+		w.WriteMsg(m)
+
+		fmt.Fprintf(stderr, "%s: NOTIFY from %s refused, %s\n", z.Name, w.RemoteAddr(), err.Error())
+
+		return
+	}
+
+	m.Authoritative = true
+
+	// The reply is signed with the key of the NOTIFY.
+	if tsig := r.IsTsig(); tsig != nil {
+		m.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsigFudge, time.Now().Unix())
+	}
+
+	w.WriteMsg(m)
+
+	fmt.Fprintf(stdout, "%s: NOTIFY from %s\n", z.Name, w.RemoteAddr())
+
+	s.schedule(z)
+}
+
+// schedule will sync z once no NOTIFY has been received for the debounce
+// period.
+func (s *secondary) schedule(z *watchedZone) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	if s.stopped {
+		return
+	}
+
+	if z.timer != nil && z.timer.Stop() {
+		s.pending.Done()
+	}
+
+	s.pending.Add(1)
+	z.timer = time.AfterFunc(s.debounce, func() {
+		defer s.pending.Done()
+
+		s.refresh(z)
+	})
+}
+
+// Stop will cancel the scheduled refreshes and wait for running refreshes
+// to finish. No refreshes are scheduled after Stop.
+func (s *secondary) Stop() {
+	s.stateMu.Lock()
+	s.stopped = true
+
+	for _, z := range s.zones {
+		if z.timer != nil && z.timer.Stop() {
+			s.pending.Done()
+		}
+	}
+	s.stateMu.Unlock()
+
+	s.pending.Wait()
+}
+
+// scheduleAll will schedule a sync of all zones.
+func (s *secondary) scheduleAll() {
+	for _, z := range s.zones {
+		s.schedule(z)
+	}
+}
+
+// querySerial will return the SOA serial of zone at server.
+func querySerial(server string, zone string, key tsigKey) (uint32, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(zone, dns.TypeSOA)
+
+	c := &dns.Client{
+		Net:        "tcp",
+		TsigSecret: key.sign(m),
+	}
+
+	response, _, err := c.Exchange(m, server)
+	if err != nil {
+		return 0, err
+	}
+
+	if response.Rcode != dns.RcodeSuccess {
+		return 0, fmt.Errorf("SOA query refused by %s: %s", server, dns.RcodeToString[response.Rcode])
+	}
+
+	for _, rr := range response.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no SOA record for '%s' returned by %s", zone, server)
+}
+
+// refresh will sync z unless the serial at the primary is unchanged since
+// the last successful sync.
+func (s *secondary) refresh(z *watchedZone) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	serial, changes, err := s.sync(z)
+
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	z.LastCheck = now()
+
+	if err == errUnchanged {
+		return
+	}
+
+	if err != nil {
+		z.LastError = err.Error()
+		fmt.Fprintf(stderr, "%s: %s\n", z.Name, err.Error())
+
+		return
+	}
+
+	z.LastSync = z.LastCheck
+	z.Serial = serial
+	z.Synced = true
+	z.LastError = ""
+	z.Changes = changes
+
+	fmt.Fprintf(stdout, "%s: serial %d synced, %d change(s)\n", z.Name, serial, changes)
+}
+
+// sync will transfer z from the primary and sync it to the provider. It
+// returns the serial synced and the number of changes.
+func (s *secondary) sync(z *watchedZone) (uint32, int, error) {
+	serial, err := querySerial(z.server, dns.Fqdn(z.Name), s.key)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	s.stateMu.Lock()
+	unchanged := z.Synced && z.Serial == serial
+	s.stateMu.Unlock()
+
+	if unchanged {
+		return serial, 0, errUnchanged
+	}
+
+	zf, err := loadZone(z.URL)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	p, err := planZone(s.connect, zf)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = applyPlans([]*plan{p}, 1)[0]
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return serial, p.Changes(), nil
+}
+
+// Status will return the status of all zones ordered by name.
+func (s *secondary) Status() []zoneStatus {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	result := make([]zoneStatus, 0, len(s.zones))
+	for _, z := range s.zones {
+		result = append(result, zoneStatus{
+			Zone:      z.Name,
+			Source:    z.URL,
+			Serial:    z.Serial,
+			Synced:    z.Synced,
+			LastCheck: z.LastCheck,
+			LastSync:  z.LastSync,
+			LastError: z.LastError,
+			Changes:   z.Changes,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Zone < result[j].Zone
+	})
+
+	return result
+}
+
+// Healthy will return true if the last sync of every zone succeeded.
+func (s *secondary) Healthy() bool {
+	for _, status := range s.Status() {
+		if !status.Synced || status.LastError != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Handler will return a HTTP handler exposing /healthz and /status.
+func (s *secondary) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !s.Healthy() {
+			http.Error(w, "unhealthy", http.StatusServiceUnavailable)
+
+			return
+		}
+
+		fmt.Fprintf(w, "ok\n")
+	})
+
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		json.NewEncoder(w).Encode(s.Status())
+	})
+
+	return mux
+}
+
+// serveFlags will register the flags for "cfzone serve".
+func serveFlags(flagset *flag.FlagSet) {
+	flagset.StringVar(&listenAddr, "listen", ":53", "Listen for NOTIFY messages on `address`")
+	flagset.StringVar(&httpAddr, "http", ":8053", "Serve /healthz and /status on `address` (empty to disable)")
+	flagset.DurationVar(&debounce, "debounce", 5*time.Second, "Wait `duration` after the last NOTIFY before syncing")
+	flagset.DurationVar(&refreshInterval, "refresh", time.Hour, "Check for changes every `duration` without NOTIFY (0 to disable)")
+	allowNotify = networkList{}
+	flagset.Var(&allowNotify, "allow-notify", "Accept NOTIFY from `address` or network besides the primary servers (can be repeated)")
+}
+
+// serveCommand implements "cfzone serve". It will keep the zones given as
+// axfr:// URLs in sync, syncing when the primary sends a NOTIFY.
+func serveCommand(args []string) {
+	urls, err := parseArguments(args, serveFlags)
+	if err != nil {
+		exit(1)
+	}
+
+	err = loadCredentials()
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err.Error())
+		exit(1)
+	}
+
+	key, err := newTSIGKey(tsigName, tsigAlgorithm, tsigSecret)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err.Error())
+		exit(1)
+	}
+
+	connect, err := newConnector()
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err.Error())
+		exit(1)
+	}
+
+	s, err := newSecondary(connect, key, debounce, allowNotify, urls)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err.Error())
+		exit(1)
+	}
+
+	// There's no one to confirm changes, and prompts are answered with no.
+	yes = true
+	stdin = strings.NewReader("")
+
+	servers := []*dns.Server{}
+	for _, network := range []string{"udp", "tcp"} {
+		server := &dns.Server{
+			Addr:       listenAddr,
+			Net:        network,
+			Handler:    s,
+			TsigSecret: key.secrets(),
+		}
+
+		go func() {
+			err := server.ListenAndServe()
+			if err != nil {
+				fmt.Fprintf(stderr, "Can't listen on %s: %s\n", listenAddr, err.Error())
+				exit(1)
+			}
+		}()
+
+		servers = append(servers, server)
+	}
+
+	if httpAddr != "" {
+		go func() {
+			err := http.ListenAndServe(httpAddr, s.Handler())
+			if err != nil {
+				fmt.Fprintf(stderr, "Can't listen on %s: %s\n", httpAddr, err.Error())
+				exit(1)
+			}
+		}()
+	}
+
+	s.scheduleAll()
+
+	if refreshInterval > 0 {
+		go func() {
+			for range time.Tick(refreshInterval) {
+				s.scheduleAll()
+			}
+		}()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+
+	for _, server := range servers {
+		server.Shutdown()
+	}
+
+	// Wait for a running sync to finish, leaving the lock behind would
+	// block the next run.
+	s.Stop()
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// fakeResponseWriter is a dns.ResponseWriter recording the reply. Messages
+// come from remote, or 127.0.0.1 if not set.
+type fakeResponseWriter struct {
+	reply      *dns.Msg
+	remote     net.IP
+	tsigStatus error
+}
+
+func (f *fakeResponseWriter) LocalAddr() net.Addr       { return &net.UDPAddr{} }
+func (f *fakeResponseWriter) WriteMsg(m *dns.Msg) error { f.reply = m; return nil }
+func (f *fakeResponseWriter) Write([]byte) (int, error) { return 0, nil }
+func (f *fakeResponseWriter) Close() error              { return nil }
+func (f *fakeResponseWriter) TsigStatus() error         { return f.tsigStatus }
+
+func (f *fakeResponseWriter) RemoteAddr() net.Addr {
+	if f.remote == nil {
+		return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	}
+
+	return &net.UDPAddr{IP: f.remote}
+}
+func (f *fakeResponseWriter) TsigTimersOnly(bool) {}
+func (f *fakeResponseWriter) Hijack()             {}
+
+func testSecondary(t *testing.T, provider Provider) (*secondary, func()) {
+	_, addr, stop := startTestDNSServer(t, "example.com.",
+		"www.example.com. 300 IN A 127.0.0.1",
+	)
+
+	_, err := parseArguments([]string{"./test", "-tsig-key", "cfzone", "-tsig-secret", testTsigSecret, "axfr://" + addr + "/example.com"})
+	if err != nil {
+		t.Fatalf("parseArguments() returned error: %s", err.Error())
+	}
+
+	key, _ := newTSIGKey(tsigName, tsigAlgorithm, tsigSecret)
+
+	s, err := newSecondary(fakeConnector(provider), key, time.Millisecond, nil, []string{"axfr://" + addr + "/example.com"})
+	if err != nil {
+		t.Fatalf("newSecondary() returned error: %s", err.Error())
+	}
+
+	// Refreshes scheduled by NOTIFY messages must not outlive the test.
+	t.Cleanup(s.Stop)
+
+	return s, func() {
+		s.Stop()
+		stop()
+		tsigName = ""
+		tsigSecret = ""
+	}
+}
+
+func TestNewSecondary(t *testing.T) {
+	_, err := newSecondary(nil, tsigKey{}, 0, nil, []string{"axfr://ns1/example.com", "axfr://ns2/example.com."})
+	if err == nil {
+		t.Errorf("newSecondary() failed to err on duplicate zone")
+	}
+
+	_, err = newSecondary(nil, tsigKey{}, 0, nil, []string{"example.com.zone"})
+	if err == nil {
+		t.Errorf("newSecondary() failed to err on zone file")
+	}
+}
+
+func TestSecondaryRefresh(t *testing.T) {
+	defer func(y bool) { yes = y }(yes)
+	yes = true
+
+	provider := &fakeProvider{}
+
+	s, stop := testSecondary(t, provider)
+	defer stop()
+
+	z := s.zones["example.com."]
+
+	s.refresh(z)
+
+	if !z.Synced || z.Serial != 1 || z.LastError != "" || z.Changes != 1 {
+		t.Fatalf("refresh() did not sync zone: %+v", z)
+	}
+
+	records, _ := provider.Records(Record{Type: "A"})
+	if len(records) != 1 || records[0].Name != "www.example.com" {
+		t.Errorf("refresh() did not sync records: %+v", provider.records)
+	}
+
+	synced := z.LastSync
+
+	s.refresh(z)
+
+	if z.LastSync != synced {
+		t.Errorf("refresh() synced unchanged zone")
+	}
+
+	if !s.Healthy() {
+		t.Errorf("Healthy() returned false after successful sync")
+	}
+}
+
+func TestSecondaryNotify(t *testing.T) {
+	defer func(y bool) { yes = y }(yes)
+	yes = true
+
+	s, stop := testSecondary(t, &fakeProvider{})
+	defer stop()
+
+	w := &fakeResponseWriter{}
+	m := new(dns.Msg)
+	m.SetQuestion("example.net.", dns.TypeA)
+	s.ServeDNS(w, m)
+
+	if w.reply.Rcode != dns.RcodeRefused {
+		t.Errorf("ServeDNS() did not refuse query, got %s", dns.RcodeToString[w.reply.Rcode])
+	}
+
+	m.SetNotify("example.net.")
+	s.ServeDNS(w, m)
+
+	if w.reply.Rcode != dns.RcodeNotAuth {
+		t.Errorf("ServeDNS() did not refuse NOTIFY for unknown zone, got %s", dns.RcodeToString[w.reply.Rcode])
+	}
+
+	recorder := httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("/healthz returned %d before sync", recorder.Code)
+	}
+
+	m.SetNotify("example.com.")
+	s.key.sign(m)
+	s.ServeDNS(w, m)
+
+	if w.reply.Rcode != dns.RcodeSuccess || !w.reply.Authoritative {
+		t.Errorf("ServeDNS() did not accept NOTIFY, got %s", dns.RcodeToString[w.reply.Rcode])
+	}
+
+	for i := 0; i < 100 && !s.Healthy(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	recorder = httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("/healthz returned %d after NOTIFY", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	s.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/status", nil))
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("/status returned %d", recorder.Code)
+	}
+}
+
+func TestSecondaryStop(t *testing.T) {
+	provider := &fakeProvider{}
+
+	s, stop := testSecondary(t, provider)
+	defer stop()
+
+	z := s.zones["example.com."]
+
+	s.debounce = time.Hour
+	s.schedule(z)
+	s.Stop()
+
+	s.debounce = time.Millisecond
+	s.schedule(z)
+	time.Sleep(20 * time.Millisecond)
+
+	if !z.LastCheck.IsZero() || len(provider.records) != 0 {
+		t.Errorf("Stop() did not cancel refreshes: %+v", z)
+	}
+}
+
+func TestSecondaryNotifyRefused(t *testing.T) {
+	s, stop := testSecondary(t, &fakeProvider{})
+	defer stop()
+
+	signed := func() *dns.Msg {
+		m := new(dns.Msg)
+		m.SetNotify("example.com.")
+		s.key.sign(m)
+
+		return m
+	}
+
+	unsigned := new(dns.Msg)
+	unsigned.SetNotify("example.com.")
+
+	otherKey := new(dns.Msg)
+	otherKey.SetNotify("example.com.")
+	otherKey.SetTsig("other.", dns.HmacSHA256, tsigFudge, time.Now().Unix())
+
+	cases := []struct {
+		w *fakeResponseWriter
+		m *dns.Msg
+	}{
+		{&fakeResponseWriter{}, unsigned},
+		{&fakeResponseWriter{}, otherKey},
+		{&fakeResponseWriter{tsigStatus: dns.ErrSig}, signed()},
+		{&fakeResponseWriter{remote: net.ParseIP("192.0.2.1")}, signed()},
+	}
+
+	for i, in := range cases {
+		s.ServeDNS(in.w, in.m)
+
+		if in.w.reply.Rcode != dns.RcodeRefused {
+			t.Errorf("%d: ServeDNS() did not refuse NOTIFY, got %s", i, dns.RcodeToString[in.w.reply.Rcode])
+		}
+	}
+
+	// Other sources can be allowed.
+	s.allowNotify.Set("192.0.2.0/24")
+
+	w := &fakeResponseWriter{remote: net.ParseIP("192.0.2.1")}
+	s.ServeDNS(w, signed())
+
+	if w.reply.Rcode != dns.RcodeSuccess {
+		t.Errorf("ServeDNS() did not accept NOTIFY from allowed network, got %s", dns.RcodeToString[w.reply.Rcode])
+	}
+}
+
+func TestNetworkList(t *testing.T) {
+	n := networkList{}
+
+	for _, value := range []string{"192.0.2.1", "2001:db8::/32"} {
+		err := n.Set(value)
+		if err != nil {
+			t.Fatalf("Set(%s) returned error: %s", value, err.Error())
+		}
+	}
+
+	if n.Set("example.com") == nil {
+		t.Errorf("Set() failed to err on hostname")
+	}
+
+	cases := map[string]bool{
+		"192.0.2.1":   true,
+		"192.0.2.2":   false,
+		"2001:db8::1": true,
+		"2001:db9::1": false,
+	}
+
+	for in, expected := range cases {
+		if n.Contains(net.ParseIP(in)) != expected {
+			t.Errorf("Contains(%s) returned %v", in, !expected)
+		}
+	}
+
+	if n.String() != "192.0.2.1/32,2001:db8::/32" {
+		t.Errorf("String() returned '%s'", n.String())
+	}
+}
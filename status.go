@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// versionPrefix is prepended to the zone name to form the name of the
+	// TXT record holding the version of cfzone last used on the zone.
+	versionPrefix = "cfzone-version."
+
+	// deployPrefix is prepended to the zone name to form the name of the TXT
+	// record holding metadata about the last deployment.
+	deployPrefix = "cfzone-deploy."
+)
+
+// deployInfo is the metadata stored about a deployment.
+type deployInfo struct {
+	Checksum string
+	Time     time.Time
+	User     string
+	Commit   string
+}
+
+// runUser will return a string identifying the user or CI job running
+// cfzone.
+func runUser() string {
+	host, _ := os.Hostname()
+
+	user := os.Getenv("USER") + "@" + host
+	if job := os.Getenv("CI_JOB_ID"); job != "" {
+		user += "/job-" + job
+	} else if run := os.Getenv("GITHUB_RUN_ID"); run != "" {
+		user += "/run-" + run
+	}
+
+	// Commas are used to separate fields in the cfzone TXT records.
+	return strings.Replace(user, ",", "_", -1)
+}
+
+// gitCommit will return the git commit being deployed as reported by CI.
+func gitCommit() string {
+	for _, name := range []string{"CI_COMMIT_SHA", "GITHUB_SHA"} {
+		if commit := os.Getenv(name); commit != "" {
+			return commit
+		}
+	}
+
+	return ""
+}
+
+// String will return the content of a deploy record for d.
+func (d deployInfo) String() string {
+	s := fmt.Sprintf("sha256=%s,time=%s,user=%s",
+		d.Checksum,
+		d.Time.UTC().Format(time.RFC3339),
+		d.User)
+
+	if d.Commit != "" {
+		s += ",commit=" + d.Commit
+	}
+
+	return s
+}
+
+// parseDeployInfo will parse the content of a deploy record.
+func parseDeployInfo(content string) (deployInfo, error) {
+	var d deployInfo
+	var err error
+
+	for _, field := range strings.Split(content, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return d, fmt.Errorf("malformed deploy field '%s'", field)
+		}
+
+		switch kv[0] {
+		case "sha256":
+			d.Checksum = kv[1]
+		case "time":
+			d.Time, err = time.Parse(time.RFC3339, kv[1])
+		case "user":
+			d.User = kv[1]
+		case "commit":
+			d.Commit = kv[1]
+		}
+
+		if err != nil {
+			return d, err
+		}
+	}
+
+	if d.Checksum == "" {
+		return d, errors.New("deploy record has no checksum")
+	}
+
+	return d, nil
+}
+
+// isDeployRecord will return true if r is a deploy record.
+func isDeployRecord(r Record) bool {
+	return r.Type == "TXT" && strings.HasPrefix(r.Name, deployPrefix)
+}
+
+// statusCommand implements "cfzone status". It will print the metadata
+// stored by cfzone in a zone.
+func statusCommand(args []string) {
+	flagset := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flagset.Usage = func() {
+		fmt.Fprintf(flagset.Output(), "Usage of %s status [flags] zone-name:\n", os.Args[0])
+		flagset.PrintDefaults()
+	}
+	flagset.SetOutput(stderr)
+	accountFlags(flagset)
+
+	err := flagset.Parse(args[1:])
+	if err == nil && flagset.NArg() != 1 {
+		err = errors.New("Zone name must be specified")
+		fmt.Fprintln(flagset.Output(), err)
+		flagset.Usage()
+	}
+
+	if err == nil {
+		err = applyConfig(flagset)
+		if err != nil {
+			fmt.Fprintln(flagset.Output(), err)
+		}
+	}
+
+	if err != nil {
+		exit(1)
+	}
+
+	zoneName := strings.Trim(flagset.Arg(0), ".")
+
+	err = loadCredentials()
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err.Error())
+		exit(1)
+	}
+
+	connect, err := newCloudflareConnector()
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err.Error())
+		exit(1)
+	}
+
+	provider, err := connect(zoneName, currentOptions())
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err.Error())
+		exit(1)
+	}
+
+	versions, err := provider.Records(Record{Type: "TXT", Name: versionPrefix + zoneName})
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err.Error())
+		exit(1)
+	}
+
+	deploys, err := provider.Records(Record{Type: "TXT", Name: deployPrefix + zoneName})
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err.Error())
+		exit(1)
+	}
+
+	if len(versions) == 0 {
+		fmt.Fprintf(stdout, "'%s' has not been synced by cfzone\n", zoneName)
+		exit(1)
+	}
+
+	deployedVersion, _ := strconv.Atoi(versions[0].Content)
+
+	fmt.Fprintf(stdout, "%-16s %s\n", "Zone:", zoneName)
+	fmt.Fprintf(stdout, "%-16s %d\n", "cfzone version:", deployedVersion)
+
+	if len(deploys) == 0 {
+		fmt.Fprintf(stdout, "No deployment metadata found\n")
+
+		return
+	}
+
+	d, err := parseDeployInfo(deploys[0].Content)
+	if err != nil {
+		fmt.Fprintf(stderr, "Can't parse deployment metadata: %s\n", err.Error())
+		exit(1)
+	}
+
+	fmt.Fprintf(stdout, "%-16s %s\n", "SHA256 checksum:", d.Checksum)
+	fmt.Fprintf(stdout, "%-16s %s\n", "Deployed:", d.Time.UTC().Format(time.RFC3339))
+	fmt.Fprintf(stdout, "%-16s %s\n", "Deployed by:", d.User)
+
+	if d.Commit != "" {
+		fmt.Fprintf(stdout, "%-16s %s\n", "Commit:", d.Commit)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeployInfo(t *testing.T) {
+	deployed := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	cases := []deployInfo{
+		{Checksum: "abc", Time: deployed, User: "agent@host"},
+		{Checksum: "abc", Time: deployed, User: "agent@host/job-42", Commit: "0123456789abcdef"},
+	}
+
+	for i, in := range cases {
+		out, err := parseDeployInfo(in.String())
+		if err != nil {
+			t.Fatalf("%d: parseDeployInfo() returned error: %s", i, err.Error())
+		}
+
+		if out != in {
+			t.Errorf("%d: parseDeployInfo() returned wrong info, got %+v, expected %+v", i, out, in)
+		}
+	}
+
+	for i, content := range []string{"", "2019121201", "time=yesterday,sha256=abc", "user=agent"} {
+		_, err := parseDeployInfo(content)
+		if err == nil {
+			t.Errorf("%d: parseDeployInfo() failed to err on '%s'", i, content)
+		}
+	}
+}
+
+func TestStatusMissingArgument(t *testing.T) {
+	defer expectExit(t, 1)
+
+	statusCommand([]string{"status"})
+}
+
+func TestStatusProfile(t *testing.T) {
+	defer func(token, key string) { apiToken, apiKey = token, key }(apiToken, apiKey)
+	defer func() {
+		stderr = os.Stderr
+		credentialFile, configPath, profileName = "", defaultConfigFile, defaultProfile
+	}()
+
+	apiToken, apiKey = "", ""
+
+	// Settings for flags not used by status are skipped.
+	path := writeTestConfig(t, "profiles:\n  prod:\n    autottl: 300\n    credentials:\n      file: /nonexistent/token\n")
+	defer os.RemoveAll(filepath.Dir(path))
+
+	out := &bytes.Buffer{}
+	stderr = out
+
+	func() {
+		defer expectExit(t, 1)
+
+		statusCommand([]string{"status", "-config", path, "-profile", "prod", "example.com"})
+	}()
+
+	if !strings.Contains(out.String(), "Can't read credentials from -credential-file") {
+		t.Errorf("statusCommand() did not use the credentials of the profile, got '%s'", out.String())
+	}
+}
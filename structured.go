@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// yamlFormat and jsonFormat are the structured zone formats.
+	yamlFormat = "yaml"
+	jsonFormat = "json"
+
+	// autoTTLValue is the TTL meaning automatic in structured zones.
+	autoTTLValue = "auto"
+)
+
+// structuredDataFields lists the fields of the structured data for each
+// record type in the order of the BIND presentation format.
+var structuredDataFields = map[string][]string{
+	"MX": {"priority", "target"},
+}
+
+type (
+	// structuredZone is a zone in the YAML or JSON zone format.
+	structuredZone struct {
+		Zone    string             `yaml:"zone" json:"zone"`
+		TTL     *structuredTTL     `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+		Records []structuredRecord `yaml:"records" json:"records"`
+	}
+
+	// structuredRecord is a record in the YAML or JSON zone format.
+	structuredRecord struct {
+		Name     string                 `yaml:"name" json:"name"`
+		Type     string                 `yaml:"type" json:"type"`
+		Content  string                 `yaml:"content,omitempty" json:"content,omitempty"`
+		TTL      *structuredTTL         `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+		Proxied  bool                   `yaml:"proxied,omitempty" json:"proxied,omitempty"`
+		Priority *int                   `yaml:"priority,omitempty" json:"priority,omitempty"`
+		Comment  string                 `yaml:"comment,omitempty" json:"comment,omitempty"`
+		Tags     []string               `yaml:"tags,omitempty" json:"tags,omitempty"`
+		Data     map[string]interface{} `yaml:"data,omitempty" json:"data,omitempty"`
+	}
+
+	// structuredTTL is a TTL in seconds, or automatic when written as
+	// "auto".
+	structuredTTL struct {
+		Auto    bool
+		Seconds int
+	}
+)
+
+// structuredFormat will return the structured zone format of the file at
+// path judged by its extension, or an empty string for BIND zone files.
+func structuredFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yamlFormat
+
+	case ".json":
+		return jsonFormat
+	}
+
+	return ""
+}
+
+// parse will set t from s.
+func (t *structuredTTL) parse(s string) error {
+	if s == autoTTLValue {
+		*t = structuredTTL{Auto: true}
+
+		return nil
+	}
+
+	seconds, err := strconv.Atoi(s)
+	if err != nil || seconds < 1 {
+		return fmt.Errorf("invalid TTL '%s', expected seconds or '%s'", s, autoTTLValue)
+	}
+
+	*t = structuredTTL{Seconds: seconds}
+
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (t *structuredTTL) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+
+	err := unmarshal(&s)
+	if err != nil {
+		return err
+	}
+
+	return t.parse(s)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *structuredTTL) UnmarshalJSON(b []byte) error {
+	var s string
+
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		// Numbers are used as is.
+		s = string(b)
+	}
+
+	return t.parse(s)
+}
+
+// value will return t as written in structured zones.
+func (t structuredTTL) value() interface{} {
+	if t.Auto {
+		return autoTTLValue
+	}
+
+	return t.Seconds
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (t structuredTTL) MarshalYAML() (interface{}, error) {
+	return t.value(), nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t structuredTTL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.value())
+}
+
+// parseStructuredZone will parse a zone in the YAML or JSON zone format.
+// The origin is used as the zone name if the zone doesn't name it.
+func parseStructuredZone(b []byte, format string, origin string) (*parsedZone, error) {
+	s := structuredZone{}
+
+	var err error
+	switch format {
+	case yamlFormat:
+		err = yaml.UnmarshalStrict(b, &s)
+
+	case jsonFormat:
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		err = d.Decode(&s)
+
+	default:
+		err = fmt.Errorf("unknown zone format '%s'", format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	z := &parsedZone{
+		Name:      strings.Trim(s.Zone, "."),
+		Records:   recordCollection{},
+		Protected: recordCollection{},
+	}
+
+	if z.Name == "" {
+		z.Name = strings.Trim(origin, ".")
+	}
+
+	if z.Name == "" {
+		return nil, errors.New("Zone name not found")
+	}
+
+	// Records without a TTL use the TTL of the zone, or automatic TTL.
+	defaultTTL := structuredTTL{Auto: true}
+	if s.TTL != nil {
+		defaultTTL = *s.TTL
+	}
+
+	for i, in := range s.Records {
+		r, rr, err := in.record(z.Name, defaultTTL)
+		if err != nil {
+			return nil, fmt.Errorf("record %d (%s %s): %s", i+1, in.Name, in.Type, err.Error())
+		}
+
+		if r == nil {
+			z.Skipped = append(z.Skipped, rr)
+
+			continue
+		}
+
+		z.Records = append(z.Records, *r)
+
+		if strings.Contains(r.Comment, protectAnnotation) {
+			z.Protected = append(z.Protected, *r)
+		}
+	}
+
+	return z, nil
+}
+
+// owner will return the fully qualified name of s in the zone called
+// zoneName. "@" is the zone itself, names ending with a dot are absolute.
+func (s structuredRecord) owner(zoneName string) string {
+	switch {
+	case s.Name == "" || s.Name == "@":
+		return dns.Fqdn(zoneName)
+
+	case strings.HasSuffix(s.Name, "."):
+		return s.Name
+	}
+
+	return dns.Fqdn(s.Name + "." + zoneName)
+}
+
+// rdata will return the data of s in the BIND presentation format.
+func (s structuredRecord) rdata(recordType string) (string, error) {
+	if s.Data != nil {
+		return s.structuredData(recordType)
+	}
+
+	switch recordType {
+	case "MX":
+		if s.Priority == nil {
+			return "", errors.New("priority is required for MX records")
+		}
+
+		return fmt.Sprintf("%d %s", *s.Priority, s.Content), nil
+
+	case "TXT":
+		chunks := []string{}
+		for _, chunk := range splitTXT(s.Content) {
+			chunks = append(chunks, quoteTXT(chunk))
+		}
+
+		return strings.Join(chunks, " "), nil
+	}
+
+	if s.Priority != nil {
+		return "", fmt.Errorf("priority is not used by %s records", recordType)
+	}
+
+	return s.Content, nil
+}
+
+// structuredData will return the structured data of s in the BIND
+// presentation format.
+func (s structuredRecord) structuredData(recordType string) (string, error) {
+	fields, found := structuredDataFields[recordType]
+	if !found {
+		return "", fmt.Errorf("structured data is not supported for %s records", recordType)
+	}
+
+	if s.Content != "" || s.Priority != nil {
+		return "", errors.New("content and priority can't be combined with data")
+	}
+
+	values := []string{}
+	for _, field := range fields {
+		value, found := s.Data[field]
+		if !found {
+			return "", fmt.Errorf("data field '%s' is missing", field)
+		}
+
+		switch value.(type) {
+		case string, int, float64:
+		default:
+			return "", fmt.Errorf("data field '%s' must be a string or a number", field)
+		}
+
+		values = append(values, fmt.Sprintf("%v", value))
+	}
+
+	if len(s.Data) > len(fields) {
+		known := map[string]bool{}
+		for _, field := range fields {
+			known[field] = true
+		}
+
+		unknown := []string{}
+		for field := range s.Data {
+			if !known[field] {
+				unknown = append(unknown, field)
+			}
+		}
+
+		sort.Strings(unknown)
+
+		return "", fmt.Errorf("unknown data field(s) %s", strings.Join(unknown, ", "))
+	}
+
+	return strings.Join(values, " "), nil
+}
+
+// record will convert s to a Record in the zone called zoneName. The
+// Record is nil for records ignored by cfzone, like for BIND zone files,
+// the resource record is returned for those.
+func (s structuredRecord) record(zoneName string, defaultTTL structuredTTL) (*Record, dns.RR, error) {
+	recordType := strings.ToUpper(s.Type)
+	if recordType == "" {
+		return nil, nil, errors.New("type is required")
+	}
+
+	ttl := defaultTTL
+	if s.TTL != nil {
+		ttl = *s.TTL
+	}
+
+	if s.Proxied && s.TTL != nil && !s.TTL.Auto {
+		return nil, nil, errors.New("proxied records use the TTL of Cloudflare, use 'auto' or leave out the TTL")
+	}
+
+	rdata, err := s.rdata(recordType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The record is converted by way of the BIND presentation format, it
+	// will then be read exactly like records from BIND zone files.
+	seconds := ttl.Seconds
+	if ttl.Auto {
+		seconds = 300
+	}
+
+	rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", s.owner(zoneName), seconds, recordType, rdata))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if rr == nil {
+		return nil, nil, errors.New("content is required")
+	}
+
+	r, err := newRecord(rr, -1, -1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if r == nil {
+		return nil, rr, nil
+	}
+
+	switch {
+	case s.Proxied:
+		r.Proxied = true
+		r.TTL = cfCacheTTL
+
+	case ttl.Auto:
+		r.TTL = cfAutoTTL
+	}
+
+	r.Comment = s.Comment
+	r.Tags = s.Tags
+
+	return r, rr, nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testStructuredBIND = `$ORIGIN example.com.
+@ 3600 IN SOA ns1.example.com. hostmaster.example.com. 1 3600 600 86400 300
+@ 300 IN A 127.0.0.1
+www 1 IN CNAME example.com.
+mail 0 IN A 127.0.0.2
+@ 300 IN MX 10 mail.example.com.
+@ 300 IN TXT "v=spf1 -all"
+txt 300 IN TXT "say \"hi\" \\o/"
+`
+
+const testStructuredYAML = `zone: example.com
+ttl: 300
+records:
+  - name: "@"
+    type: A
+    content: 127.0.0.1
+  - name: www
+    type: CNAME
+    content: example.com
+    proxied: true
+  - name: mail.example.com.
+    type: A
+    content: 127.0.0.2
+    ttl: auto
+  - name: "@"
+    type: MX
+    data:
+      priority: 10
+      target: mail.example.com.
+  - name: "@"
+    type: TXT
+    content: v=spf1 -all
+  - name: txt
+    type: TXT
+    content: 'say "hi" \o/'
+`
+
+const testStructuredJSON = `{
+  "zone": "example.com",
+  "records": [
+    {"name": "@", "type": "A", "content": "127.0.0.1", "ttl": 300},
+    {"name": "www", "type": "CNAME", "content": "example.com", "proxied": true},
+    {"name": "mail", "type": "A", "content": "127.0.0.2", "ttl": "auto"},
+    {"name": "@", "type": "MX", "content": "mail.example.com", "priority": 10, "ttl": 300},
+    {"name": "@", "type": "TXT", "content": "v=spf1 -all", "ttl": 300},
+    {"name": "txt", "type": "TXT", "content": "say \"hi\" \\o/", "ttl": 300}
+  ]
+}`
+
+func TestParseStructuredZone(t *testing.T) {
+	name, expected, err := parseZone(strings.NewReader(testStructuredBIND))
+	if err != nil {
+		t.Fatalf("parseZone() returned error: %s", err.Error())
+	}
+
+	// Lines are only known for BIND zone files.
+	for i := range expected {
+		expected[i].Line = 0
+	}
+
+	cases := []struct {
+		format string
+		in     string
+	}{
+		{yamlFormat, testStructuredYAML},
+		{jsonFormat, testStructuredJSON},
+	}
+
+	for _, in := range cases {
+		z, err := parseStructuredZone([]byte(in.in), in.format, "")
+		if err != nil {
+			t.Errorf("%s: parseStructuredZone() returned error: %s", in.format, err.Error())
+
+			continue
+		}
+
+		if z.Name != name {
+			t.Errorf("%s: parseStructuredZone() returned zone name '%s', expected '%s'", in.format, z.Name, name)
+		}
+
+		if !reflect.DeepEqual(z.Records, expected) {
+			t.Errorf("%s: parseStructuredZone() returned %+v, expected %+v", in.format, z.Records, expected)
+		}
+	}
+}
+
+func TestParseStructuredZoneRecords(t *testing.T) {
+	cases := []struct {
+		in       string
+		expected Record
+	}{
+		{`{name: long, type: TXT, content: ` + strings.Repeat("x", 300) + `}`, Record{Type: "TXT", Name: "long.example.com", Content: strings.Repeat("x", 300)}},
+		{`{name: www, type: A, content: 127.0.0.1, comment: "web server", tags: [web, prod]}`, Record{Type: "A", Name: "www.example.com", Content: "127.0.0.1", Comment: "web server", Tags: []string{"web", "prod"}}},
+		{`{name: www, type: AAAA, content: "::1", ttl: 60}`, Record{Type: "AAAA", Name: "www.example.com", Content: "::1", TTL: 60}},
+	}
+
+	for i, in := range cases {
+		z, err := parseStructuredZone([]byte("zone: example.com\nrecords:\n  - "+in.in+"\n"), yamlFormat, "")
+		if err != nil {
+			t.Errorf("%d: parseStructuredZone() returned error: %s", i, err.Error())
+
+			continue
+		}
+
+		if len(z.Records) != 1 || !reflect.DeepEqual(z.Records[0], in.expected) {
+			t.Errorf("%d: parseStructuredZone() returned %+v, expected %+v", i, z.Records, in.expected)
+		}
+	}
+}
+
+func TestParseStructuredZoneProtected(t *testing.T) {
+	in := `zone: example.com
+records:
+  - {name: www, type: A, content: 127.0.0.1, comment: "cfzone:protected"}
+  - {name: ftp, type: A, content: 127.0.0.1}
+`
+
+	z, err := parseStructuredZone([]byte(in), yamlFormat, "")
+	if err != nil {
+		t.Fatalf("parseStructuredZone() returned error: %s", err.Error())
+	}
+
+	if len(z.Protected) != 1 || z.Protected[0].Name != "www.example.com" {
+		t.Errorf("parseStructuredZone() returned wrong protected records: %+v", z.Protected)
+	}
+}
+
+func TestParseStructuredZoneErrors(t *testing.T) {
+	cases := []struct {
+		format string
+		in     string
+	}{
+		{yamlFormat, "records: []\n"},
+		{yamlFormat, "zone: example.com\nunknown: true\n"},
+		{jsonFormat, `{"zone": "example.com", "unknown": true}`},
+		{jsonFormat, `{"zone": "example.com"`},
+		{yamlFormat, "zone: example.com\nttl: 0\n"},
+		{yamlFormat, "zone: example.com\nttl: never\n"},
+		{yamlFormat, "zone: example.com\nrecords:\n  - {name: www, content: 127.0.0.1}\n"},
+		{yamlFormat, "zone: example.com\nrecords:\n  - {name: www, type: A, content: 127.0.0.1, ttl: 300, proxied: true}\n"},
+		{yamlFormat, "zone: example.com\nrecords:\n  - {name: www, type: A, content: nonsense}\n"},
+		{yamlFormat, "zone: example.com\nrecords:\n  - {name: www, type: A}\n"},
+		{yamlFormat, "zone: example.com\nrecords:\n  - {name: www, type: A, content: 127.0.0.1, priority: 10}\n"},
+		{yamlFormat, "zone: example.com\nrecords:\n  - {name: '@', type: MX, content: mail.example.com}\n"},
+		{yamlFormat, "zone: example.com\nrecords:\n  - {name: '@', type: MX, data: {priority: 10}}\n"},
+		{yamlFormat, "zone: example.com\nrecords:\n  - {name: '@', type: MX, data: {priority: 10, target: mail, weight: 1}}\n"},
+		{yamlFormat, "zone: example.com\nrecords:\n  - {name: '@', type: MX, data: {priority: [10], target: mail}}\n"},
+		{yamlFormat, "zone: example.com\nrecords:\n  - {name: '@', type: A, data: {address: 127.0.0.1}}\n"},
+		{yamlFormat, "zone: example.com\nrecords:\n  - {name: _sip._tcp, type: SRV, data: {priority: 10, weight: 5, port: 5060, target: sip}}\n"},
+		{yamlFormat, "zone: example.com\nrecords:\n  - {name: _sip._tcp, type: SRV, content: 10 5 5060 sip.example.com.}\n"},
+		{yamlFormat, "zone: example.com\nrecords:\n  - {name: '@', type: CAA, data: {flags: 0, tag: issue, value: letsencrypt.org}}\n"},
+		{yamlFormat, "zone: example.com\nrecords:\n  - {name: '@', type: CAA, content: 0 issue letsencrypt.org}\n"},
+		{"toml", "zone = 'example.com'"},
+	}
+
+	for i, in := range cases {
+		_, err := parseStructuredZone([]byte(in.in), in.format, "")
+		if err == nil {
+			t.Errorf("%d: parseStructuredZone() failed to err on %s", i, in.in)
+		}
+	}
+}
+
+func TestParseStructuredZoneIgnoreSrv(t *testing.T) {
+	defer func() { ignoreSrv = false }()
+	ignoreSrv = true
+
+	in := "zone: example.com\nrecords:\n  - {name: _sip._tcp, type: SRV, content: 10 5 5060 sip.example.com.}\n"
+
+	z, err := parseStructuredZone([]byte(in), yamlFormat, "")
+	if err != nil {
+		t.Fatalf("parseStructuredZone() returned error: %s", err.Error())
+	}
+
+	if len(z.Records) != 0 || len(z.Skipped) != 1 || z.Skipped[0] == nil {
+		t.Errorf("parseStructuredZone() did not skip SRV record: %+v, %v", z.Records, z.Skipped)
+	}
+}
+
+func TestParseStructuredZoneREADME(t *testing.T) {
+	b, err := ioutil.ReadFile("README.md")
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %s", err.Error())
+	}
+
+	// The example is the first YAML zone in the README.
+	readme := string(b)
+	start := strings.Index(readme, "```yaml\nzone:")
+	if start < 0 {
+		t.Fatalf("README.md has no structured zone example")
+	}
+
+	example := readme[start+len("```yaml\n"):]
+	example = example[:strings.Index(example, "```")]
+
+	z, err := parseStructuredZone([]byte(example), yamlFormat, "")
+	if err != nil {
+		t.Fatalf("parseStructuredZone() returned error for the README example: %s", err.Error())
+	}
+
+	if len(z.Records) != 4 || len(z.Skipped) != 0 || len(z.Protected) != 1 {
+		t.Errorf("parseStructuredZone() returned wrong zone for the README example: %+v", z)
+	}
+}
+
+func TestStructuredFormat(t *testing.T) {
+	cases := map[string]string{
+		"example.com.yaml": yamlFormat,
+		"example.com.YML":  yamlFormat,
+		"example.com.json": jsonFormat,
+		"example.com.zone": "",
+		"example.com":      "",
+	}
+
+	for in, expected := range cases {
+		if structuredFormat(in) != expected {
+			t.Errorf("structuredFormat(%s) returned '%s', expected '%s'", in, structuredFormat(in), expected)
+		}
+	}
+}
+
+func TestLoadStructuredZone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "example.com.yaml")
+	ioutil.WriteFile(path, []byte(testStructuredYAML), 0600)
+
+	zf, err := loadZone(path)
+	if err != nil {
+		t.Fatalf("loadZone() returned error: %s", err.Error())
+	}
+
+	if zf.Zone.Name != "example.com" || len(zf.Zone.Records) != 6 {
+		t.Errorf("loadZone() returned wrong zone: %+v", zf.Zone)
+	}
+}
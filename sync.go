@@ -0,0 +1,743 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// errAborted is returned when the user aborts a sync.
+var errAborted = errors.New("aborted by user")
+
+type (
+	// options holds the settings for syncing a single zone. The settings
+	// can differ between zones, when overridden in the profile.
+	options struct {
+		LeaveUnknown     bool
+		Owner            string
+		MaxDeletes       int
+		MaxChangePercent float64
+		ProtectRules     ignoreRules
+		ZoneID           string
+		AccountID        string
+		CNAMEFlattening  string
+	}
+
+	// zoneFile is a parsed zone file ready for planning. A zone can be
+	// made up of fragments from several files.
+	zoneFile struct {
+		Paths    []string
+		Zone     *parsedZone
+		Checksum []byte
+		Rules    ignoreRules
+		Options  options
+	}
+
+	// plan holds the changes needed to sync a zone file to its provider.
+	plan struct {
+		File     *zoneFile
+		Provider Provider
+		Lock     *zoneLock
+
+		Deletes recordCollection
+		Adds    recordCollection
+		Updates recordCollection
+
+		// Protected holds the protected records in the zone. Violations
+		// holds the protected records deleted or changed, which is only
+		// allowed with -allow-protected.
+		Protected  recordCollection
+		Violations recordCollection
+
+		// Unchanged is the number of records left as is.
+		Unchanged int
+
+		// Flattening is the CNAME flattening mode to set, or empty if it's
+		// left as is. flatteningWas is the current mode.
+		Flattening    string
+		flatteningWas string
+
+		// These are bookkeeping changes made by cfzone. They're applied
+		// along with the changes above, but never shown to the user.
+		extraDeletes recordCollection
+		extraAdds    recordCollection
+		extraUpdates recordCollection
+	}
+)
+
+// currentOptions will return the options as set by flags, the environment
+// and the configuration file.
+func currentOptions() options {
+	return options{
+		LeaveUnknown:     leaveUnknown,
+		Owner:            owner,
+		MaxDeletes:       maxDeletes,
+		MaxChangePercent: maxChangePercent,
+		ProtectRules:     append(ignoreRules{}, protectRules...),
+		ZoneID:           zoneID,
+		AccountID:        accountID,
+		CNAMEFlattening:  string(cnameFlattening),
+	}
+}
+
+// expandPaths will replace directories in paths with the zone files found
+// in them. All files not starting with a dot are considered zone files.
+func expandPaths(paths []string) ([]string, error) {
+	result := []string{}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			// Errors are reported when opening the file.
+			result = append(result, path)
+
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading '%s': %s", path, err.Error())
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+
+			result = append(result, filepath.Join(path, entry.Name()))
+		}
+	}
+
+	return result, nil
+}
+
+// loadZone will read and parse the zone file at path, and find the options
+// and ignore rules for the zone. Files ending in .yaml, .yml or .json are
+// read as structured zones. path can also be an axfr:// URL.
+func loadZone(path string) (*zoneFile, error) {
+	var parse func() (*parsedZone, error)
+	var checksum []byte
+
+	if isAXFRURL(path) {
+		server, name, err := parseAXFRURL(path)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid zone URL '%s': %s", path, err.Error())
+		}
+
+		key, err := newTSIGKey(tsigName, tsigAlgorithm, tsigSecret)
+		if err != nil {
+			return nil, err
+		}
+
+		rrs, err := transferZone(server, dns.Fqdn(name), key)
+		if err != nil {
+			return nil, err
+		}
+
+		checksum = transferChecksum(rrs)
+		parse = func() (*parsedZone, error) {
+			zone, err := parseTransfer(name, rrs, zoneAutoTTL, zoneCacheTTL)
+			if err != nil {
+				return nil, fmt.Errorf("Error reading '%s': %s", path, err.Error())
+			}
+
+			return zone, nil
+		}
+	} else {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error opening '%s': %s", path, err.Error())
+		}
+
+		sum := sha256.Sum256(b)
+		checksum = sum[:]
+		format := structuredFormat(path)
+		parse = func() (*parsedZone, error) {
+			var zone *parsedZone
+			if format != "" {
+				zone, err = parseStructuredZone(b, format, origin)
+			} else {
+				zone, err = parseZoneFile(bytes.NewReader(b), origin, zoneAutoTTL, zoneCacheTTL)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Error reading '%s': %s", path, err.Error())
+			}
+
+			return zone, nil
+		}
+	}
+
+	zone, err := parse()
+	if err != nil {
+		return nil, err
+	}
+
+	zf := &zoneFile{
+		Paths:    []string{path},
+		Checksum: checksum,
+	}
+
+	err = withZoneConfig(zone.Name, func(found bool) error {
+		// Settings for the zone can change how the zone file is parsed. We
+		// parse it again if any was found.
+		if found {
+			zone, err = parse()
+			if err != nil {
+				return err
+			}
+		}
+
+		zf.Rules, err = zoneIgnoreRules(path, zone.Name)
+		if err != nil {
+			return err
+		}
+
+		zf.Options = currentOptions()
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	zone.Name = normalizeName(zone.Name)
+	zone.Records = zone.Records.Normalize().Ignore(zf.Rules)
+	zone.Protected = zone.Protected.Normalize()
+	zone.setSources(path)
+	zf.Zone = zone
+
+	err = zone.Records.checkWildcards()
+	if err != nil {
+		return nil, fmt.Errorf("Error reading '%s': %s", path, err.Error())
+	}
+
+	return zf, nil
+}
+
+// zoneIgnoreRules will return the ignore rules for the zone file at path
+// from the ignore file, the profile and the flags.
+func zoneIgnoreRules(path string, zoneName string) (ignoreRules, error) {
+	// Zones read by zone transfer only use the ignore file given by
+	// -ignorefile.
+	ignorePath := ignoreFile
+	if ignorePath == "" && !isAXFRURL(path) {
+		ignorePath = filepath.Join(filepath.Dir(path), ignoreFileName)
+	}
+
+	rules := ignoreRules{}
+	if ignorePath != "" {
+		var err error
+
+		rules, err = readIgnoreFile(ignorePath, ignoreFile != "")
+		if err != nil {
+			return nil, fmt.Errorf("Error reading ignore file '%s': %s", ignorePath, err.Error())
+		}
+	}
+
+	profileRules, err := configIgnoreRules(zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	rules = append(rules, profileRules...)
+
+	if ignoreSrv {
+		rules = append(rules, ignoreRule{Type: "SRV"})
+	}
+
+	if ignoreSpf {
+		rules = append(rules, ignoreRule{Type: "SPF"})
+	}
+
+	return rules, nil
+}
+
+// planZone will lock the zone at the provider and compute the changes
+// needed to sync the zone file. The lock is held until the plan is applied
+// or released.
+func planZone(connect connector, zf *zoneFile) (*plan, error) {
+	zone := zf.Zone
+
+	provider, err := connect(zone.Name, zf.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	err = provider.Capabilities().Check(zone.Records)
+	if err != nil {
+		return nil, fmt.Errorf("Can't sync '%s': %s", zone.Name, err.Error())
+	}
+
+	if zone.apexCNAME() != nil && !provider.Capabilities().ApexCNAME {
+		return nil, fmt.Errorf("Can't sync '%s': CNAME at the zone apex is not supported by the provider", zone.Name)
+	}
+
+	if _, ok := provider.(flatteningProvider); zf.Options.CNAMEFlattening != "" && !ok {
+		return nil, fmt.Errorf("Can't sync '%s': CNAME flattening is not supported by the provider", zone.Name)
+	}
+
+	lock, err := acquireLock(provider, zone.Name, lockOwner(), lockTTL, lockWait)
+	if err != nil {
+		return nil, fmt.Errorf("Can't lock '%s': %s", zone.Name, err.Error())
+	}
+
+	p := &plan{
+		File:     zf,
+		Provider: provider,
+		Lock:     lock,
+	}
+
+	err = p.compute()
+	if err != nil {
+		p.Release()
+
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// compute will compute the changes for p.
+func (p *plan) compute() error {
+	zone := p.File.Zone
+	opts := p.File.Options
+
+	allRecords, err := p.Provider.Records(Record{})
+	if err != nil {
+		return err
+	}
+
+	// Records are compared in their canonical form, like the records from
+	// the zone file.
+	allRecords = allRecords.Normalize()
+
+	versionRecord := Record{
+		Name:    versionPrefix + zone.Name,
+		Content: strconv.Itoa(version),
+		Type:    "TXT",
+		TTL:     600,
+	}
+
+	// The bookkeeping records are sorted out before the ignore rules are
+	// applied, a rule like "type=TXT" must not hide them.
+	var records = make([]Record, 0, len(allRecords))
+	ownerRecords := recordCollection{}
+	deployRecords := recordCollection{}
+	for _, record := range allRecords {
+		if isLockRecord(record) {
+			continue
+		}
+		if isDeployRecord(record) {
+			deployRecords = append(deployRecords, record)
+			continue
+		}
+		if isOwnerRecord(record) {
+			ownerRecords = append(ownerRecords, record)
+			continue
+		}
+		if !Updatable(record, versionRecord) && p.File.Rules.Match(record) {
+			continue
+		}
+		records = append(records, record)
+	}
+	existingRecords := recordCollection(records)
+
+	n, versionRecordFound := existingRecords.Find(versionRecord, Updatable)
+	if versionRecordFound != nil {
+		deployedVersion, _ := strconv.Atoi(versionRecordFound.Content)
+
+		// Check if we risk "downgrading" the setup at the provider.
+		if deployedVersion > version {
+			fmt.Fprintf(stdout,
+				"Deployed version (%d) of '%s' is newer than current version (%d). Continue (y/N)? ",
+				deployedVersion,
+				zone.Name,
+				version)
+
+			if !yesNo(stdin) {
+				return errAborted
+			}
+		}
+
+		existingRecords.Remove(n)
+	}
+
+	// Find records only present at the provider - and records only present in
+	// the file zone. This will be the basis for the add/delete collections.
+	addCandidates := zone.Records.Difference(existingRecords, FullMatch)
+	deleteCandidates := existingRecords.Difference(zone.Records, FullMatch)
+
+	// If we find the intersection between file and existing, we should have
+	// a list of records to update. We use only Updatable here, because that
+	// will give us a collection of records that makes sense to update.
+	p.Updates = deleteCandidates.Intersect(addCandidates, Updatable)
+
+	// The records to be updated can be removed from the add and delete
+	// collections.
+	p.Adds = addCandidates.Difference(p.Updates, Updatable)
+	p.Deletes = deleteCandidates.Difference(p.Updates, Updatable)
+
+	p.Unchanged = len(existingRecords) - len(deleteCandidates)
+
+	if len(p.Deletes) > 0 && opts.LeaveUnknown {
+		fmt.Fprintf(stdout, "%d unknown records in '%s' left untouched\n", len(p.Deletes), zone.Name)
+		p.Deletes = p.Deletes[:0]
+	}
+
+	if len(p.Deletes) > 0 && opts.Owner != "" {
+		var unowned recordCollection
+
+		p.Deletes, unowned = p.Deletes.splitOwned(ownerRecords, opts.Owner)
+		if len(unowned) > 0 {
+			fmt.Fprintf(stdout, "%d records in '%s' not owned by '%s' left untouched\n", len(unowned), zone.Name, opts.Owner)
+		}
+	}
+
+	err = checkThresholds(len(p.Deletes), len(p.Updates), len(existingRecords), opts.MaxDeletes, opts.MaxChangePercent)
+	if err != nil {
+		if !force {
+			return fmt.Errorf("Refusing to sync '%s': %s. Use -force to sync anyway.", zone.Name, err.Error())
+		}
+
+		fmt.Fprintf(stdout, "Warning: %s\n", err.Error())
+	}
+
+	protected := protection{
+		Rules:     opts.ProtectRules,
+		Annotated: zone.Protected,
+	}
+
+	violations := protected.Violations(p.Deletes, p.Updates, existingRecords)
+	if len(violations) > 0 {
+		if !allowProtected {
+			var b bytes.Buffer
+			violations.Fprint(&b)
+
+			return fmt.Errorf("Refusing to delete or change protected records in '%s':\n%sUse -allow-protected to sync anyway.", zone.Name, b.String())
+		}
+
+		fmt.Fprintf(stdout, "Warning: %d protected record(s) in '%s' will be deleted or changed:\n", len(violations), zone.Name)
+		violations.Fprint(stdout)
+
+		p.Violations = violations
+	}
+
+	p.Protected = protected.Records(existingRecords)
+
+	if opts.CNAMEFlattening != "" {
+		current, err := p.Provider.(flatteningProvider).CNAMEFlattening()
+		if err != nil {
+			return err
+		}
+
+		if current != opts.CNAMEFlattening {
+			p.Flattening = opts.CNAMEFlattening
+			p.flatteningWas = current
+		}
+	}
+
+	// The bookkeeping records are kept out of the diff to avoid polluting
+	// the diff and confusing the user.
+	p.extraDeletes = recordCollection{}
+	p.extraAdds = recordCollection{}
+	p.extraUpdates = recordCollection{}
+
+	if versionRecordFound != nil {
+		if versionRecordFound.Content != versionRecord.Content {
+			versionRecordFound.Content = versionRecord.Content
+
+			p.extraUpdates = append(p.extraUpdates, *versionRecordFound)
+		}
+	} else {
+		p.extraAdds = append(p.extraAdds, versionRecord)
+	}
+
+	// The deployment metadata is updated if anything changed, including the
+	// zone file itself.
+	deploy := deployInfo{
+		Checksum: fmt.Sprintf("%x", p.File.Checksum),
+		Time:     now(),
+		User:     runUser(),
+		Commit:   commit,
+	}
+
+	deployRecord := Record{
+		Name:    deployPrefix + zone.Name,
+		Content: deploy.String(),
+		Type:    "TXT",
+		TTL:     600,
+	}
+
+	if len(deployRecords) == 0 {
+		p.extraAdds = append(p.extraAdds, deployRecord)
+	} else if deployed, err := parseDeployInfo(deployRecords[0].Content); err != nil || deployed.Checksum != deploy.Checksum || p.Changes() > 0 {
+		deployRecord.ID = deployRecords[0].ID
+		p.extraUpdates = append(p.extraUpdates, deployRecord)
+	}
+
+	if opts.Owner != "" {
+		markerAdds, markerDeletes := ownerRecords.ownerChanges(opts.Owner, zone.Records, existingRecords.Difference(p.Deletes, FullMatch))
+
+		p.extraAdds = append(p.extraAdds, markerAdds...)
+		p.extraDeletes = append(p.extraDeletes, markerDeletes...)
+	}
+
+	return nil
+}
+
+// Changes will return the number of changes shown to the user.
+func (p *plan) Changes() int {
+	changes := len(p.Deletes) + len(p.Adds) + len(p.Updates)
+	if p.Flattening != "" {
+		changes++
+	}
+
+	return changes
+}
+
+// Fprint will output the changes in p.
+func (p *plan) Fprint(w io.Writer) {
+	if len(p.Deletes) > 0 {
+		fmt.Fprintf(w, "Records to delete:\n")
+		p.Deletes.Fprint(w)
+		fmt.Fprintf(w, "\n")
+	}
+
+	if len(p.Adds) > 0 {
+		fmt.Fprintf(w, "Records to add:\n")
+		p.Adds.Fprint(w)
+		fmt.Fprintf(w, "\n")
+	}
+
+	if len(p.Updates) > 0 {
+		fmt.Fprintf(w, "Records to update:\n")
+		p.Updates.Fprint(w)
+		fmt.Fprintf(w, "\n")
+	}
+
+	if p.Flattening != "" {
+		fmt.Fprintf(w, "Zone settings to update:\n")
+		fmt.Fprintf(w, "cname-flattening: %s -> %s\n", p.flatteningWas, p.Flattening)
+		fmt.Fprintf(w, "\n")
+	}
+
+	if len(p.Protected) > 0 {
+		fmt.Fprintf(w, "Protected records:\n")
+		p.Protected.Fprint(w)
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+// FprintSummary will output a summary of the changes in p.
+func (p *plan) FprintSummary(w io.Writer) {
+	fmt.Fprintf(w, "SHA256 zone checksum: %x\n", p.File.Checksum)
+	fmt.Fprintf(w, "Records to delete: %d\n", len(p.Deletes))
+	fmt.Fprintf(w, "Records to add: %d\n", len(p.Adds))
+	fmt.Fprintf(w, "Records to update: %d\n", len(p.Updates))
+	fmt.Fprintf(w, "Unchanged records: %d\n", p.Unchanged)
+
+	// Wildcards answer for every name below them without records of its
+	// own, changing them can affect many names.
+	wildcards := len(p.Deletes.Wildcards()) + len(p.Adds.Wildcards()) + len(p.Updates.Wildcards())
+	if wildcards > 0 {
+		fmt.Fprintf(w, "Wildcard records changed: %d\n", wildcards)
+	}
+
+	if len(p.Violations) > 0 {
+		fmt.Fprintf(w, "Protected records deleted or changed: %d\n", len(p.Violations))
+		p.Violations.Fprint(w)
+	}
+}
+
+// Apply will apply the changes in p at the provider. The lock is checked
+// first, the user could have taken longer than -lock-ttl to confirm.
+func (p *plan) Apply() error {
+	err := p.Lock.Refresh(lockTTL)
+	if err != nil {
+		return fmt.Errorf("Can't sync '%s': %s", p.File.Zone.Name, err.Error())
+	}
+
+	deletes := append(p.Deletes.Clone(), p.extraDeletes...)
+	adds := append(p.Adds.Clone(), p.extraAdds...)
+	updates := append(p.Updates.Clone(), p.extraUpdates...)
+
+	for _, r := range deletes {
+		err := p.Provider.Delete(r)
+		if err != nil {
+			return fmt.Errorf("Failed to delete record %+v: %s", r, err.Error())
+		}
+	}
+
+	for _, r := range adds {
+		_, err := p.Provider.Create(r)
+		if err != nil {
+			return fmt.Errorf("Failed to add record %+v: %s", r, err.Error())
+		}
+	}
+
+	for _, r := range updates {
+		err := p.Provider.Update(r)
+		if err != nil {
+			return fmt.Errorf("Failed to update record %+v: %s", r, err.Error())
+		}
+	}
+
+	if p.Flattening != "" {
+		err := p.Provider.(flatteningProvider).SetCNAMEFlattening(p.Flattening)
+		if err != nil {
+			return fmt.Errorf("Failed to set CNAME flattening to '%s': %s", p.Flattening, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Release will release the lock held for p.
+func (p *plan) Release() error {
+	err := p.Lock.Release()
+	if err != nil {
+		return fmt.Errorf("Failed to release lock for '%s': %s", p.File.Zone.Name, err.Error())
+	}
+
+	return nil
+}
+
+// applyPlans will apply plans using up to parallel concurrent workers and
+// release the locks. The returned slice holds the result for each plan.
+func applyPlans(plans []*plan, parallel int) []error {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]error, len(plans))
+	sem := make(chan struct{}, parallel)
+
+	var wg sync.WaitGroup
+
+	for i, p := range plans {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, p *plan) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[i] = p.Apply()
+
+			err := p.Release()
+			if results[i] == nil {
+				results[i] = err
+			}
+		}(i, p)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// syncZones will plan the changes for all files, ask the user for
+// confirmation once and apply the changes. It returns the exit code for
+// cfzone.
+func syncZones(connect connector, files []*zoneFile) int {
+	plans := make([]*plan, 0, len(files))
+
+	release := func() {
+		for _, p := range plans {
+			err := p.Release()
+			if err != nil {
+				fmt.Fprintf(stderr, "%s\n", err.Error())
+			}
+		}
+	}
+
+	for _, zf := range files {
+		p, err := planZone(connect, zf)
+		if err == errAborted {
+			release()
+			fmt.Fprintf(stdout, "Aborting...\n")
+
+			return 0
+		}
+
+		if err != nil {
+			release()
+			fmt.Fprintf(stderr, "%s\n", err.Error())
+
+			return 1
+		}
+
+		plans = append(plans, p)
+	}
+
+	multiple := len(plans) > 1
+
+	numChanges := 0
+	for _, p := range plans {
+		numChanges += p.Changes()
+	}
+
+	if numChanges > 0 && !yes {
+		for _, p := range plans {
+			if p.Changes() == 0 {
+				continue
+			}
+
+			if multiple {
+				fmt.Fprintf(stdout, "Zone '%s' (%s):\n", p.File.Zone.Name, strings.Join(p.File.Paths, ", "))
+			}
+
+			p.Fprint(stdout)
+		}
+
+		fmt.Fprintf(stdout, "Summary:\n")
+		for _, p := range plans {
+			if multiple {
+				fmt.Fprintf(stdout, "Zone '%s':\n", p.File.Zone.Name)
+			}
+
+			p.FprintSummary(stdout)
+		}
+
+		fmt.Fprintf(stdout, "%d change(s). Continue (y/N)? ", numChanges)
+
+		if !yesNo(stdin) {
+			release()
+			fmt.Fprintf(stdout, "Aborting...\n")
+
+			return 0
+		}
+	}
+
+	code := 0
+	for i, err := range applyPlans(plans, parallel) {
+		name := plans[i].File.Zone.Name
+
+		if err != nil {
+			fmt.Fprintf(stderr, "%s\n", err.Error())
+			code = 1
+		}
+
+		if !multiple {
+			continue
+		}
+
+		if err != nil {
+			fmt.Fprintf(stdout, "%s: failed\n", name)
+		} else {
+			fmt.Fprintf(stdout, "%s: %d change(s) applied\n", name, plans[i].Changes())
+		}
+	}
+
+	return code
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"example.com", "example.net", ignoreFileName} {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte{}, 0600)
+	}
+	os.Mkdir(filepath.Join(dir, "sub"), 0700)
+
+	paths, err := expandPaths([]string{"single", dir})
+	if err != nil {
+		t.Fatalf("expandPaths() returned error: %s", err.Error())
+	}
+
+	expected := []string{
+		"single",
+		filepath.Join(dir, "example.com"),
+		filepath.Join(dir, "example.net"),
+	}
+
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expandPaths() returned %v, expected %v", paths, expected)
+	}
+}
+
+func TestLoadZone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	zone := `$ORIGIN example.com.
+@ 300 IN SOA ns1.example.com. hostmaster.example.com. 1 3600 600 86400 300
+@ 300 IN A 127.0.0.1
+www 300 IN A 127.0.0.1
+_acme-challenge 300 IN TXT "token"
+`
+
+	path := filepath.Join(dir, "example.com")
+	ioutil.WriteFile(path, []byte(zone), 0600)
+	ioutil.WriteFile(filepath.Join(dir, ignoreFileName), []byte("_acme-challenge.*\n"), 0600)
+
+	// parseArguments changes the settings of later tests.
+	defer func(o string, p ignoreRules, c flatteningMode, gc string) {
+		owner, protectRules, cnameFlattening, commit = o, p, c, gc
+	}(owner, protectRules, cnameFlattening, commit)
+	defer func(p *profile, f *flag.FlagSet, e map[string]bool) {
+		activeProfile, activeFlags, explicitFlags = p, f, e
+	}(activeProfile, activeFlags, explicitFlags)
+
+	_, err = parseArguments([]string{"./test", "-owner", "ci", path})
+	if err != nil {
+		t.Fatalf("parseArguments() returned error: %s", err.Error())
+	}
+
+	zf, err := loadZone(path)
+	if err != nil {
+		t.Fatalf("loadZone() returned error: %s", err.Error())
+	}
+
+	if zf.Zone.Name != "example.com" || len(zf.Zone.Records) != 2 {
+		t.Errorf("loadZone() returned wrong zone: %+v", zf.Zone)
+	}
+
+	if len(zf.Rules) != 1 || zf.Options.Owner != "ci" || len(zf.Checksum) != 32 {
+		t.Errorf("loadZone() returned wrong settings: %+v", zf)
+	}
+
+	_, err = loadZone(filepath.Join(dir, "missing"))
+	if err == nil {
+		t.Errorf("loadZone() failed to err on missing file")
+	}
+}
+
+func TestSyncZones(t *testing.T) {
+	defer func(y bool) { yes = y }(yes)
+	yes = true
+
+	provider := &fakeProvider{
+		records: recordCollection{
+			{ID: "a", Type: "A", Name: "www.example.com", Content: "127.0.0.1", TTL: 300},
+			{ID: "b", Type: "A", Name: "old.example.com", Content: "127.0.0.1", TTL: 300},
+			{ID: "c", Type: "MX", Name: "example.com", Content: "mx.example.com", TTL: 300, Priority: 10},
+		},
+		nextID: 100,
+	}
+
+	zf := &zoneFile{
+		Paths: []string{"example.com"},
+		Zone: &parsedZone{
+			Name: "example.com",
+			Records: recordCollection{
+				{Type: "A", Name: "www.example.com", Content: "127.0.0.2", TTL: 300},
+				{Type: "A", Name: "new.example.com", Content: "127.0.0.1", TTL: 300},
+				{Type: "MX", Name: "example.com", Content: "mx.example.com", TTL: 300, Priority: 10},
+			},
+		},
+		Options: options{MaxDeletes: -1, MaxChangePercent: -1},
+	}
+
+	code := syncZones(fakeConnector(provider), []*zoneFile{zf})
+	if code != 0 {
+		t.Fatalf("syncZones() returned %d", code)
+	}
+
+	records := recordCollection{}
+	for _, r := range provider.records {
+		if !strings.HasPrefix(r.Name, "cfzone-") {
+			records = append(records, r)
+		}
+	}
+
+	expected := zf.Zone.Records
+	if len(records.Difference(expected, FullMatch)) != 0 || len(expected.Difference(records, FullMatch)) != 0 {
+		t.Errorf("syncZones() did not sync records, got %+v", records)
+	}
+
+	if len(provider.records) != 5 {
+		t.Errorf("syncZones() did not add version and deploy records, got %+v", provider.records)
+	}
+
+	for _, r := range provider.records {
+		if isLockRecord(r) {
+			t.Errorf("syncZones() did not release the lock")
+		}
+	}
+}
+
+func TestSyncZonesIgnoreTXT(t *testing.T) {
+	defer func(y bool) { yes = y }(yes)
+	yes = true
+
+	rules := ignoreRules{}
+	err := rules.Set("type=TXT")
+	if err != nil {
+		t.Fatalf("Set() returned error: %s", err.Error())
+	}
+
+	provider := &fakeProvider{
+		records: recordCollection{
+			{ID: "a", Type: "TXT", Name: "example.com", Content: "v=spf1 -all", TTL: 300},
+		},
+		nextID: 100,
+	}
+
+	zf := &zoneFile{
+		Paths: []string{"example.com"},
+		Zone: &parsedZone{
+			Name: "example.com",
+			Records: recordCollection{
+				{Type: "A", Name: "www.example.com", Content: "127.0.0.1", TTL: 300},
+			},
+		},
+		Rules:   rules,
+		Options: options{Owner: "ci", MaxDeletes: -1, MaxChangePercent: -1},
+	}
+
+	code := syncZones(fakeConnector(provider), []*zoneFile{zf})
+	if code != 0 {
+		t.Fatalf("syncZones() returned %d", code)
+	}
+
+	first := len(provider.records)
+
+	code = syncZones(fakeConnector(provider), []*zoneFile{zf})
+	if code != 0 {
+		t.Fatalf("syncZones() returned %d on second run", code)
+	}
+
+	if len(provider.records) != first {
+		t.Errorf("syncZones() added bookkeeping records again, got %+v", provider.records)
+	}
+
+	names := map[string]int{}
+	for _, r := range provider.records {
+		names[r.Name]++
+	}
+
+	for _, name := range []string{versionPrefix + "example.com", deployPrefix + "example.com"} {
+		if names[name] != 1 {
+			t.Errorf("syncZones() left %d '%s' records, expected 1", names[name], name)
+		}
+	}
+}
+
+func TestApplyLostLock(t *testing.T) {
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	defer fakeNow(start)()
+
+	provider := &fakeProvider{}
+
+	zf := &zoneFile{
+		Paths: []string{"example.com"},
+		Zone: &parsedZone{
+			Name: "example.com",
+			Records: recordCollection{
+				{Type: "A", Name: "new.example.com", Content: "127.0.0.1", TTL: 300},
+			},
+		},
+		Options: options{MaxDeletes: -1, MaxChangePercent: -1},
+	}
+
+	p, err := planZone(fakeConnector(provider), zf)
+	if err != nil {
+		t.Fatalf("planZone() returned error: %s", err.Error())
+	}
+
+	// The user waits at the prompt while another run breaks the lock.
+	fakeNow(start.Add(lockTTL + time.Minute))
+
+	_, err = acquireLock(provider, "example.com", "other", lockTTL, 0)
+	if err != nil {
+		t.Fatalf("acquireLock() did not break stale lock: %s", err.Error())
+	}
+
+	err = applyPlans([]*plan{p}, 1)[0]
+	if err == nil || !strings.Contains(err.Error(), "lock was lost") {
+		t.Errorf("applyPlans() returned wrong error for lost lock: %v", err)
+	}
+
+	for _, r := range provider.records {
+		if r.Name == "new.example.com" {
+			t.Errorf("applyPlans() applied changes without holding the lock")
+		}
+	}
+}
+
+func TestSyncZonesFailure(t *testing.T) {
+	defer func(y bool) { yes = y }(yes)
+	yes = true
+
+	provider := &fakeProvider{failOn: "new.example.com"}
+
+	zf := &zoneFile{
+		Paths: []string{"example.com"},
+		Zone: &parsedZone{
+			Name: "example.com",
+			Records: recordCollection{
+				{Type: "A", Name: "new.example.com", Content: "127.0.0.1", TTL: 300},
+			},
+		},
+		Options: options{MaxDeletes: -1, MaxChangePercent: -1},
+	}
+
+	code := syncZones(fakeConnector(provider), []*zoneFile{zf})
+	if code != 1 {
+		t.Errorf("syncZones() returned %d for failing provider, expected 1", code)
+	}
+
+	for _, r := range provider.records {
+		if isLockRecord(r) {
+			t.Errorf("syncZones() did not release the lock after failure")
+		}
+	}
+}
+
+func TestPlanZoneThresholds(t *testing.T) {
+	defer func(f bool) { force = f }(force)
+	defer func() { stdout = os.Stdout }()
+
+	provider := &fakeProvider{
+		records: recordCollection{
+			{ID: "a", Type: "A", Name: "a.example.com", Content: "127.0.0.1", TTL: 300},
+			{ID: "b", Type: "A", Name: "b.example.com", Content: "127.0.0.1", TTL: 300},
+			{ID: "c", Type: "A", Name: "c.example.com", Content: "127.0.0.1", TTL: 300},
+			{ID: "d", Type: "A", Name: "d.example.com", Content: "127.0.0.1", TTL: 300},
+		},
+		nextID: 100,
+	}
+
+	// The zone file deletes two of four records and updates one.
+	zone := &parsedZone{
+		Name: "example.com",
+		Records: recordCollection{
+			{Type: "A", Name: "a.example.com", Content: "127.0.0.1", TTL: 300},
+			{Type: "A", Name: "b.example.com", Content: "127.0.0.2", TTL: 300},
+		},
+	}
+
+	cases := []struct {
+		opts   options
+		force  bool
+		refuse bool
+	}{
+		{options{MaxDeletes: -1, MaxChangePercent: -1}, false, false},
+		{options{MaxDeletes: 2, MaxChangePercent: -1}, false, false},
+		{options{MaxDeletes: 1, MaxChangePercent: -1}, false, true},
+		{options{MaxDeletes: -1, MaxChangePercent: 75}, false, false},
+		{options{MaxDeletes: -1, MaxChangePercent: 50}, false, true},
+		{options{MaxDeletes: 1, MaxChangePercent: -1}, true, false},
+		{options{MaxDeletes: -1, MaxChangePercent: 50}, true, false},
+	}
+
+	for i, in := range cases {
+		force = in.force
+
+		out := &bytes.Buffer{}
+		stdout = out
+
+		p, err := planZone(fakeConnector(provider), &zoneFile{Paths: []string{"example.com"}, Zone: zone, Options: in.opts})
+		if in.refuse {
+			if err == nil || !strings.Contains(err.Error(), "Use -force to sync anyway") {
+				t.Errorf("%d: planZone() did not refuse to sync, got %v", i, err)
+			}
+		} else if err != nil {
+			t.Errorf("%d: planZone() returned error: %s", i, err.Error())
+		} else if len(p.Deletes) != 2 || len(p.Updates) != 1 {
+			t.Errorf("%d: planZone() returned wrong changes: %d deletes, %d updates", i, len(p.Deletes), len(p.Updates))
+		}
+
+		// -force turns the refusal into a warning.
+		if in.force && !strings.Contains(out.String(), "Warning: ") {
+			t.Errorf("%d: planZone() did not warn about the forced sync, got '%s'", i, out.String())
+		}
+
+		if p != nil {
+			p.Release()
+		}
+
+		for _, r := range provider.records {
+			if isLockRecord(r) {
+				t.Fatalf("%d: planZone() left the lock behind", i)
+			}
+		}
+	}
+}
+
+func TestPlanZoneProtected(t *testing.T) {
+	defer func(a bool) { allowProtected = a }(allowProtected)
+	defer func() { stdout = os.Stdout }()
+
+	rules := ignoreRules{}
+	rules.Set("type=MX")
+
+	provider := &fakeProvider{
+		records: recordCollection{
+			{ID: "a", Type: "A", Name: "www.example.com", Content: "127.0.0.1", TTL: 300},
+			{ID: "b", Type: "MX", Name: "example.com", Content: "mx.example.com", TTL: 300, Priority: 10},
+		},
+		nextID: 100,
+	}
+
+	annotated := Record{Type: "A", Name: "www.example.com", Content: "127.0.0.1", TTL: 300}
+
+	cases := []struct {
+		zone    *parsedZone
+		opts    options
+		allow   bool
+		refuse  bool
+		message string
+		listed  string
+	}{
+		// The MX record is deleted, but only protected by a rule.
+		{&parsedZone{Name: "example.com", Records: recordCollection{annotated}}, options{MaxDeletes: -1, MaxChangePercent: -1}, false, false, "", ""},
+		{&parsedZone{Name: "example.com", Records: recordCollection{annotated}}, options{MaxDeletes: -1, MaxChangePercent: -1, ProtectRules: rules}, false, true, "mx.example.com", ""},
+		{&parsedZone{Name: "example.com", Records: recordCollection{annotated}}, options{MaxDeletes: -1, MaxChangePercent: -1, ProtectRules: rules}, true, false, "Warning: 1 protected record(s)", "IN MX    mx.example.com"},
+
+		// The annotated A record is updated.
+		{&parsedZone{Name: "example.com", Records: recordCollection{{Type: "A", Name: "www.example.com", Content: "127.0.0.2", TTL: 300}, provider.records[1]}, Protected: recordCollection{annotated}}, options{MaxDeletes: -1, MaxChangePercent: -1}, false, true, "www.example.com", ""},
+		{&parsedZone{Name: "example.com", Records: recordCollection{{Type: "A", Name: "www.example.com", Content: "127.0.0.2", TTL: 300}, provider.records[1]}, Protected: recordCollection{annotated}}, options{MaxDeletes: -1, MaxChangePercent: -1}, true, false, "Warning: 1 protected record(s)", "www.example.com. 300 IN A     127.0.0.1"},
+	}
+
+	for i, in := range cases {
+		allowProtected = in.allow
+
+		out := &bytes.Buffer{}
+		stdout = out
+
+		p, err := planZone(fakeConnector(provider), &zoneFile{Paths: []string{"example.com"}, Zone: in.zone, Options: in.opts})
+		if in.refuse {
+			if err == nil || !strings.Contains(err.Error(), "Use -allow-protected to sync anyway") || !strings.Contains(err.Error(), in.message) {
+				t.Errorf("%d: planZone() did not refuse to change protected record, got %v", i, err)
+			}
+		} else if err != nil {
+			t.Errorf("%d: planZone() returned error: %s", i, err.Error())
+		} else if p.Changes() != 1 || !strings.Contains(out.String(), in.message) {
+			t.Errorf("%d: planZone() returned %d change(s), output '%s'", i, p.Changes(), out.String())
+		}
+
+		// The protected records changed are listed in the summary, which
+		// is all the user sees besides the warning.
+		if p != nil && in.allow {
+			var b strings.Builder
+			p.FprintSummary(&b)
+
+			if !strings.Contains(b.String(), "Protected records deleted or changed: 1\n") || !strings.Contains(b.String(), in.listed) {
+				t.Errorf("%d: FprintSummary() did not list the protected records, got '%s'", i, b.String())
+			}
+		}
+
+		if p != nil {
+			p.Release()
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// isWildcard will return true if name is a wildcard name like
+// "*.example.com".
+func isWildcard(name string) bool {
+	return strings.HasPrefix(name, "*.")
+}
+
+// wildcardParent will return the name below which the wildcard name
+// matches, "example.com" for "*.example.com".
+func wildcardParent(name string) string {
+	return strings.TrimPrefix(name, "*.")
+}
+
+// checkWildcard will return an error if the asterisk in name is anywhere
+// but as the whole leftmost label.
+func checkWildcard(name string) error {
+	if !strings.Contains(name, "*") {
+		return nil
+	}
+
+	if !isWildcard(name) || strings.Contains(wildcardParent(name), "*") {
+		return fmt.Errorf("invalid wildcard name '%s', '*' must be the whole leftmost label", name)
+	}
+
+	return nil
+}
+
+// checkWildcards will return an error for the first record in c with an
+// invalid wildcard name.
+func (c recordCollection) checkWildcards() error {
+	for _, r := range c {
+		err := checkWildcard(r.Name)
+		if err != nil {
+			return fmt.Errorf("%s%s", err.Error(), lineOf(r))
+		}
+	}
+
+	return nil
+}
+
+// Wildcards will return the wildcard records in c.
+func (c recordCollection) Wildcards() recordCollection {
+	result := recordCollection{}
+
+	for _, r := range c {
+		if isWildcard(r.Name) {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
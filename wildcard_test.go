@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckWildcard(t *testing.T) {
+	cases := map[string]bool{
+		"example.com":             true,
+		"*.example.com":           true,
+		"*.apps.example.com":      true,
+		"*":                       false,
+		"a*.example.com":          false,
+		"*a.example.com":          false,
+		"www.*.example.com":       false,
+		"*.*.example.com":         false,
+		"**.example.com":          false,
+		"www.example.com.*":       false,
+		"*.apps.example.com.*.dk": false,
+	}
+
+	for in, valid := range cases {
+		err := checkWildcard(in)
+		if valid && err != nil {
+			t.Errorf("checkWildcard(%s) returned error: %s", in, err.Error())
+		}
+
+		if !valid && err == nil {
+			t.Errorf("checkWildcard(%s) failed to err", in)
+		}
+	}
+}
+
+func TestCheckWildcards(t *testing.T) {
+	z, err := parseZoneFile(strings.NewReader("$ORIGIN example.com.\n@ 3600 IN SOA ns1 hostmaster 1 3600 600 86400 300\n*.apps 300 IN A 127.0.0.1\nwww.* 300 IN A 127.0.0.1\n"), "", cfAutoTTL, cfCacheTTL)
+	if err != nil {
+		t.Fatalf("parseZoneFile() returned error: %s", err.Error())
+	}
+
+	err = z.Records.checkWildcards()
+	if err == nil || !strings.Contains(err.Error(), "'www.*.example.com'") || !strings.HasSuffix(err.Error(), " at line 4") {
+		t.Errorf("checkWildcards() returned wrong error: %v", err)
+	}
+
+	err = z.Records[:1].checkWildcards()
+	if err != nil {
+		t.Errorf("checkWildcards() returned error: %s", err.Error())
+	}
+}
+
+func TestLoadZoneWildcard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfzone")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "example.com.zone")
+	ioutil.WriteFile(path, []byte("$ORIGIN example.com.\n@ 3600 IN SOA ns1 hostmaster 1 3600 600 86400 300\n*.Apps 300 IN A 127.0.0.1\n"), 0600)
+
+	zf, err := loadZone(path)
+	if err != nil {
+		t.Fatalf("loadZone() returned error: %s", err.Error())
+	}
+
+	if zf.Zone.Records[0].Name != "*.apps.example.com" {
+		t.Errorf("loadZone() returned wrong wildcard name '%s'", zf.Zone.Records[0].Name)
+	}
+
+	ioutil.WriteFile(path, []byte("$ORIGIN example.com.\n@ 3600 IN SOA ns1 hostmaster 1 3600 600 86400 300\napps* 300 IN A 127.0.0.1\n"), 0600)
+
+	_, err = loadZone(path)
+	if err == nil {
+		t.Errorf("loadZone() failed to err on invalid wildcard")
+	}
+}
+
+func TestWildcards(t *testing.T) {
+	c := recordCollection{
+		{Type: "A", Name: "*.example.com", Content: "127.0.0.1"},
+		{Type: "A", Name: "www.example.com", Content: "127.0.0.1"},
+		{Type: "TXT", Name: "*.apps.example.com", Content: "apps"},
+	}
+
+	w := c.Wildcards()
+	if len(w) != 2 || w[0].Name != "*.example.com" || w[1].Name != "*.apps.example.com" {
+		t.Errorf("Wildcards() returned %+v", w)
+	}
+}
+
+func TestFprintWildcard(t *testing.T) {
+	var b strings.Builder
+
+	recordCollection{
+		{Type: "A", Name: "*.example.com", Content: "127.0.0.1", TTL: 1, Proxied: true},
+	}.Fprint(&b)
+
+	if !strings.Contains(b.String(), " ; WILDCARD, PROXIED\n") {
+		t.Errorf("Fprint() didn't highlight wildcard, got '%s'", b.String())
+	}
+
+	p := &plan{
+		File: &zoneFile{},
+		Adds: recordCollection{{Type: "A", Name: "*.example.com", Content: "127.0.0.1", TTL: 300}},
+	}
+
+	b.Reset()
+	p.FprintSummary(&b)
+
+	if !strings.Contains(b.String(), "Wildcard records changed: 1\n") {
+		t.Errorf("FprintSummary() didn't count wildcard changes, got:\n%s", b.String())
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// zoneAPI is the subset of the Cloudflare API needed for finding a zone.
+type zoneAPI interface {
+	ListZonesContext(ctx context.Context, opts ...cloudflare.ReqOption) (cloudflare.ZonesResponse, error)
+	ZoneDetails(zoneID string) (cloudflare.Zone, error)
+}
+
+// resolveZoneID will find the ID of the zone called zoneName. If zoneID is
+// given it's used as is, after checking that it belongs to a zone called
+// zoneName. If accountID is given, only zones in that account are
+// considered.
+func resolveZoneID(api zoneAPI, zoneName string, zoneID string, accountID string) (string, error) {
+	if zoneID != "" {
+		zone, err := api.ZoneDetails(zoneID)
+		if err != nil {
+			return "", err
+		}
+
+		if zone.Name != zoneName {
+			return "", fmt.Errorf("zone ID %s belongs to '%s'", zoneID, zone.Name)
+		}
+
+		if accountID != "" && zone.Account.ID != accountID {
+			return "", fmt.Errorf("zone ID %s belongs to account %s", zoneID, zone.Account.ID)
+		}
+
+		return zoneID, nil
+	}
+
+	res, err := api.ListZonesContext(context.Background(), cloudflare.WithZoneFilter(zoneName))
+	if err != nil {
+		return "", err
+	}
+
+	candidates := []cloudflare.Zone{}
+	for _, zone := range res.Result {
+		if zone.Name != zoneName {
+			continue
+		}
+
+		if accountID != "" && zone.Account.ID != accountID {
+			continue
+		}
+
+		candidates = append(candidates, zone)
+	}
+
+	switch len(candidates) {
+	case 0:
+		if accountID != "" {
+			return "", fmt.Errorf("zone not found in account %s", accountID)
+		}
+
+		return "", fmt.Errorf("zone not found")
+
+	case 1:
+		return candidates[0].ID, nil
+	}
+
+	list := make([]string, 0, len(candidates))
+	for _, zone := range candidates {
+		list = append(list, fmt.Sprintf("  %s (account %s, %s)", zone.ID, zone.Account.ID, zone.Account.Name))
+	}
+
+	return "", fmt.Errorf("zone name is ambiguous, use -zone-id or -account-id to select one of:\n%s", strings.Join(list, "\n"))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// fakeZoneAPI is an in-memory implementation of zoneAPI.
+type fakeZoneAPI struct {
+	zones []cloudflare.Zone
+}
+
+func (f *fakeZoneAPI) ListZonesContext(ctx context.Context, opts ...cloudflare.ReqOption) (cloudflare.ZonesResponse, error) {
+	return cloudflare.ZonesResponse{Result: f.zones}, nil
+}
+
+func (f *fakeZoneAPI) ZoneDetails(zoneID string) (cloudflare.Zone, error) {
+	for _, zone := range f.zones {
+		if zone.ID == zoneID {
+			return zone, nil
+		}
+	}
+
+	return cloudflare.Zone{}, errors.New("zone not found")
+}
+
+func testZone(id string, name string, account string) cloudflare.Zone {
+	zone := cloudflare.Zone{ID: id, Name: name}
+	zone.Account.ID = account
+	zone.Account.Name = "Account " + account
+
+	return zone
+}
+
+func TestResolveZoneID(t *testing.T) {
+	api := &fakeZoneAPI{
+		zones: []cloudflare.Zone{
+			testZone("z1", "example.com", "a1"),
+			testZone("z2", "example.com", "a2"),
+			testZone("z3", "example.net", "a1"),
+		},
+	}
+
+	cases := []struct {
+		name     string
+		zoneID   string
+		account  string
+		expected string
+		err      string
+	}{
+		{"example.net", "", "", "z3", ""},
+		{"example.com", "", "", "", "z1 (account a1"},
+		{"example.com", "", "a2", "z2", ""},
+		{"example.com", "z1", "", "z1", ""},
+		{"example.com", "z3", "", "", "belongs to 'example.net'"},
+		{"example.com", "z1", "a2", "", "belongs to account a1"},
+		{"example.org", "", "", "", "not found"},
+		{"example.net", "", "a2", "", "not found in account a2"},
+	}
+
+	for i, in := range cases {
+		id, err := resolveZoneID(api, in.name, in.zoneID, in.account)
+		if id != in.expected {
+			t.Errorf("%d: resolveZoneID() returned '%s', expected '%s'", i, id, in.expected)
+		}
+
+		if in.err == "" && err != nil {
+			t.Errorf("%d: resolveZoneID() returned error: %s", i, err.Error())
+		}
+
+		if in.err != "" && (err == nil || !strings.Contains(err.Error(), in.err)) {
+			t.Errorf("%d: resolveZoneID() returned wrong error %v, expected '%s'", i, err, in.err)
+		}
+	}
+}